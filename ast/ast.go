@@ -2,6 +2,7 @@ package ast
 
 import (
 	"bytes"
+	"fmt"
 	"monkey-lang/token"
 	"strings"
 )
@@ -9,6 +10,7 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Position() token.Position // the source position of the node's token
 }
 
 type Statement interface {
@@ -33,19 +35,34 @@ func (p *Program) TokenLiteral() string {
 	return ""
 }
 
+func (p *Program) Position() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Position()
+	}
+
+	return token.Position{}
+}
+
 type LetStatement struct {
-	Token token.Token // the token.LET token
-	Name  *Identifier
-	Value Expression
+	Token    token.Token // the token.LET or token.CONST token
+	Name     *Identifier
+	Pattern  Expression // the ArrayPattern or HashPattern to destructure Value with, Name is nil then
+	Value    Expression
+	Constant bool // declared with const, the names it binds can't be reassigned
 }
 
-func (ls *LetStatement) statementNode()       {}
-func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *LetStatement) statementNode()           {}
+func (ls *LetStatement) TokenLiteral() string     { return ls.Token.Literal }
+func (ls *LetStatement) Position() token.Position { return ls.Token.Position }
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 
 	out.WriteString(ls.TokenLiteral() + " ")
-	out.WriteString(ls.Name.String())
+	if ls.Pattern != nil {
+		out.WriteString(ls.Pattern.String())
+	} else {
+		out.WriteString(ls.Name.String())
+	}
 	out.WriteString(" = ")
 
 	if ls.Value != nil {
@@ -62,8 +79,9 @@ type ReturnStatement struct {
 	ReturnValue Expression
 }
 
-func (rs *ReturnStatement) statementNode()       {}
-func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
+func (rs *ReturnStatement) statementNode()           {}
+func (rs *ReturnStatement) TokenLiteral() string     { return rs.Token.Literal }
+func (rs *ReturnStatement) Position() token.Position { return rs.Token.Position }
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
 
@@ -83,8 +101,9 @@ type ExpressionStatement struct {
 	Expression Expression
 }
 
-func (es *ExpressionStatement) statementNode()       {}
-func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) statementNode()           {}
+func (es *ExpressionStatement) TokenLiteral() string     { return es.Token.Literal }
+func (es *ExpressionStatement) Position() token.Position { return es.Token.Position }
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
 		return es.Expression.String()
@@ -98,9 +117,10 @@ type Identifier struct {
 	Value string
 }
 
-func (i *Identifier) expressionNode()      {}
-func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
-func (i *Identifier) String() string       { return i.Value }
+func (i *Identifier) expressionNode()          {}
+func (i *Identifier) TokenLiteral() string     { return i.Token.Literal }
+func (i *Identifier) Position() token.Position { return i.Token.Position }
+func (i *Identifier) String() string           { return i.Value }
 
 func (p *Program) String() string {
 	var out bytes.Buffer
@@ -117,9 +137,20 @@ type IntegerLiteral struct {
 	Value int64
 }
 
-func (il *IntegerLiteral) expressionNode()      {}
-func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
-func (il *IntegerLiteral) String() string       { return il.Token.Literal }
+func (il *IntegerLiteral) expressionNode()          {}
+func (il *IntegerLiteral) TokenLiteral() string     { return il.Token.Literal }
+func (il *IntegerLiteral) Position() token.Position { return il.Token.Position }
+func (il *IntegerLiteral) String() string           { return il.Token.Literal }
+
+type FloatLiteral struct {
+	Token token.Token // the token.FLOAT token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode()          {}
+func (fl *FloatLiteral) TokenLiteral() string     { return fl.Token.Literal }
+func (fl *FloatLiteral) Position() token.Position { return fl.Token.Position }
+func (fl *FloatLiteral) String() string           { return fl.Token.Literal }
 
 type PrefixExpression struct {
 	Token    token.Token
@@ -127,8 +158,9 @@ type PrefixExpression struct {
 	Right    Expression
 }
 
-func (pe *PrefixExpression) expressionNode()      {}
-func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) expressionNode()          {}
+func (pe *PrefixExpression) TokenLiteral() string     { return pe.Token.Literal }
+func (pe *PrefixExpression) Position() token.Position { return pe.Token.Position }
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 
@@ -147,8 +179,9 @@ type InfixExpression struct {
 	Right    Expression
 }
 
-func (in *InfixExpression) expressionNode()      {}
-func (in *InfixExpression) TokenLiteral() string { return in.Token.Literal }
+func (in *InfixExpression) expressionNode()          {}
+func (in *InfixExpression) TokenLiteral() string     { return in.Token.Literal }
+func (in *InfixExpression) Position() token.Position { return in.Token.Position }
 func (in *InfixExpression) String() string {
 	var out bytes.Buffer
 
@@ -168,9 +201,19 @@ type Boolean struct {
 	Value bool
 }
 
-func (b *Boolean) expressionNode()      {}
-func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
-func (b *Boolean) String() string       { return b.Token.Literal }
+func (b *Boolean) expressionNode()          {}
+func (b *Boolean) TokenLiteral() string     { return b.Token.Literal }
+func (b *Boolean) Position() token.Position { return b.Token.Position }
+func (b *Boolean) String() string           { return b.Token.Literal }
+
+type NullLiteral struct {
+	Token token.Token
+}
+
+func (nl *NullLiteral) expressionNode()          {}
+func (nl *NullLiteral) TokenLiteral() string     { return nl.Token.Literal }
+func (nl *NullLiteral) Position() token.Position { return nl.Token.Position }
+func (nl *NullLiteral) String() string           { return nl.Token.Literal }
 
 type IfExpression struct {
 	Token       token.Token
@@ -179,8 +222,9 @@ type IfExpression struct {
 	Alternative *BlockStatement
 }
 
-func (ie *IfExpression) expressionNode()      {}
-func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IfExpression) expressionNode()          {}
+func (ie *IfExpression) TokenLiteral() string     { return ie.Token.Literal }
+func (ie *IfExpression) Position() token.Position { return ie.Token.Position }
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 
@@ -197,13 +241,116 @@ func (ie *IfExpression) String() string {
 	return out.String()
 }
 
+// MatchExpression evaluates the body of the first arm whose pattern matches
+// the subject, or gives null when none does
+type MatchExpression struct {
+	Token   token.Token // the 'match' token
+	Subject Expression
+	Arms    []*MatchArm
+}
+
+// MatchArm is a `pattern => body` arm of a match expression. Patterns are
+// identifiers, which bind the value they match unless they are `_`, literals,
+// negated number literals, ArrayPatterns and HashPatterns
+type MatchArm struct {
+	Pattern Expression
+	Body    *BlockStatement
+}
+
+func (me *MatchExpression) expressionNode()          {}
+func (me *MatchExpression) TokenLiteral() string     { return me.Token.Literal }
+func (me *MatchExpression) Position() token.Position { return me.Token.Position }
+func (me *MatchExpression) String() string {
+	var out bytes.Buffer
+
+	arms := []string{}
+	for _, arm := range me.Arms {
+		arms = append(arms, arm.Pattern.String()+" => "+arm.Body.String())
+	}
+
+	out.WriteString("match (")
+	out.WriteString(me.Subject.String())
+	out.WriteString(") { ")
+	out.WriteString(strings.Join(arms, ", "))
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+// ArrayPattern matches arrays of the same length whose elements match its
+// elements, or of at least that length when it has a rest element collecting
+// the remaining elements. In a let statement arrays of any length match, with
+// missing elements being null
+type ArrayPattern struct {
+	Token    token.Token // the '[' token
+	Elements []Expression
+	Rest     *Identifier // the name after `...`, nil without a rest element
+}
+
+func (ap *ArrayPattern) expressionNode()          {}
+func (ap *ArrayPattern) TokenLiteral() string     { return ap.Token.Literal }
+func (ap *ArrayPattern) Position() token.Position { return ap.Token.Position }
+func (ap *ArrayPattern) String() string {
+	elements := []string{}
+	for _, element := range ap.Elements {
+		elements = append(elements, element.String())
+	}
+	if ap.Rest != nil {
+		elements = append(elements, "..."+ap.Rest.String())
+	}
+
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// HashPattern matches hashes that have all of its keys, with values matching
+// the corresponding patterns. Keys are literals, in source order. A rest
+// element collects the pairs with other keys into a new hash. In a let
+// statement missing keys give null
+type HashPattern struct {
+	Token  token.Token // the '{' token
+	Keys   []Expression
+	Values []Expression
+	Rest   *Identifier // the name after `...`, nil without a rest element
+}
+
+func (hp *HashPattern) expressionNode()          {}
+func (hp *HashPattern) TokenLiteral() string     { return hp.Token.Literal }
+func (hp *HashPattern) Position() token.Position { return hp.Token.Position }
+func (hp *HashPattern) String() string {
+	pairs := []string{}
+	for i, key := range hp.Keys {
+		pairs = append(pairs, key.String()+": "+hp.Values[i].String())
+	}
+	if hp.Rest != nil {
+		pairs = append(pairs, "..."+hp.Rest.String())
+	}
+
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// DefaultPattern is an element of a destructuring let like the `b = 2` in
+// `let [a, b = 2] = x`, which binds Default when the element is null
+type DefaultPattern struct {
+	Token   token.Token // the '=' token
+	Target  Expression
+	Default Expression
+}
+
+func (dp *DefaultPattern) expressionNode()          {}
+func (dp *DefaultPattern) TokenLiteral() string     { return dp.Token.Literal }
+func (dp *DefaultPattern) Position() token.Position { return dp.Token.Position }
+func (dp *DefaultPattern) String() string {
+	return dp.Target.String() + " = " + dp.Default.String()
+}
+
 type BlockStatement struct {
 	Token      token.Token // the { token
 	Statements []Statement
 }
 
-func (bs *BlockStatement) expressionNode()      {}
-func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) expressionNode()          {}
+func (bs *BlockStatement) TokenLiteral() string     { return bs.Token.Literal }
+func (bs *BlockStatement) Position() token.Position { return bs.Token.Position }
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -214,23 +361,98 @@ func (bs *BlockStatement) String() string {
 	return out.String()
 }
 
+type WhileStatement struct {
+	Token     token.Token // the 'while' token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode()           {}
+func (ws *WhileStatement) TokenLiteral() string     { return ws.Token.Literal }
+func (ws *WhileStatement) Position() token.Position { return ws.Token.Position }
+func (ws *WhileStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("while")
+	out.WriteString(ws.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(ws.Body.String())
+
+	return out.String()
+}
+
+// ForStatement walks the elements of an array or a string, or the pairs of a
+// hash. Key is nil unless the loop binds two names, e.g. `for (k, v in hash)`
+type ForStatement struct {
+	Token    token.Token // the 'for' token
+	Key      *Identifier
+	Value    *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForStatement) statementNode()           {}
+func (fs *ForStatement) TokenLiteral() string     { return fs.Token.Literal }
+func (fs *ForStatement) Position() token.Position { return fs.Token.Position }
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for(")
+	if fs.Key != nil {
+		out.WriteString(fs.Key.String())
+		out.WriteString(", ")
+	}
+	out.WriteString(fs.Value.String())
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(fs.Body.String())
+
+	return out.String()
+}
+
+type BreakStatement struct {
+	Token token.Token // the 'break' token
+}
+
+func (bs *BreakStatement) statementNode()           {}
+func (bs *BreakStatement) TokenLiteral() string     { return bs.Token.Literal }
+func (bs *BreakStatement) Position() token.Position { return bs.Token.Position }
+func (bs *BreakStatement) String() string           { return bs.TokenLiteral() + ";" }
+
+type ContinueStatement struct {
+	Token token.Token // the 'continue' token
+}
+
+func (cs *ContinueStatement) statementNode()           {}
+func (cs *ContinueStatement) TokenLiteral() string     { return cs.Token.Literal }
+func (cs *ContinueStatement) Position() token.Position { return cs.Token.Position }
+func (cs *ContinueStatement) String() string           { return cs.TokenLiteral() + ";" }
+
+// FunctionExpression is a function literal. Parameters with a default value
+// come after the required ones and take it when their argument is missing or
+// null. A rest parameter collects any further arguments into an array
 type FunctionExpression struct {
 	Token      token.Token // the 'fn' token
 	Parameters []*Identifier
+	Defaults   []Expression // the default value of each parameter, nil for required ones
+	Rest       *Identifier  // the name after `...`, nil without a rest parameter
 	Body       *BlockStatement
+	Name       string // the name the function is bound to with `let`, if any
 }
 
-func (fe *FunctionExpression) expressionNode()      {}
-func (fe *FunctionExpression) TokenLiteral() string { return fe.Token.Literal }
+func (fe *FunctionExpression) expressionNode()          {}
+func (fe *FunctionExpression) TokenLiteral() string     { return fe.Token.Literal }
+func (fe *FunctionExpression) Position() token.Position { return fe.Token.Position }
 func (fe *FunctionExpression) String() string {
 	var out bytes.Buffer
 
-	parameters := []string{}
-	for _, parameter := range fe.Parameters {
-		parameters = append(parameters, parameter.String())
-	}
+	parameters := ParametersString(fe.Parameters, fe.Defaults, fe.Rest)
 
 	out.WriteString(fe.TokenLiteral())
+	if fe.Name != "" {
+		out.WriteString(fmt.Sprintf("<%s>", fe.Name))
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(parameters, ", "))
 	out.WriteString(")")
@@ -239,14 +461,44 @@ func (fe *FunctionExpression) String() string {
 	return out.String()
 }
 
+// NumRequired returns the number of parameters without a default value, which
+// come before those with one
+func (fe *FunctionExpression) NumRequired() int {
+	numRequired := 0
+	for numRequired < len(fe.Defaults) && fe.Defaults[numRequired] == nil {
+		numRequired++
+	}
+	return numRequired
+}
+
+// ParametersString formats the parameters of a function like `x, y = 2, ...rest`
+func ParametersString(parameters []*Identifier, defaults []Expression, rest *Identifier) []string {
+	out := []string{}
+	for i, parameter := range parameters {
+		if i < len(defaults) && defaults[i] != nil {
+			out = append(out, parameter.String()+" = "+defaults[i].String())
+		} else {
+			out = append(out, parameter.String())
+		}
+	}
+
+	if rest != nil {
+		out = append(out, "..."+rest.String())
+	}
+
+	return out
+}
+
 type CallExpression struct {
-	Token     token.Token // the ( token
+	Token     token.Token // the ( token, or ?. for an optional call
 	Function  Expression
 	Arguments []Expression
+	Optional  bool // `f?.(x)` gives null instead of calling f when it is null
 }
 
-func (ce *CallExpression) expressionNode()      {}
-func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) expressionNode()          {}
+func (ce *CallExpression) TokenLiteral() string     { return ce.Token.Literal }
+func (ce *CallExpression) Position() token.Position { return ce.Token.Position }
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -256,6 +508,9 @@ func (ce *CallExpression) String() string {
 	}
 
 	out.WriteString(ce.Function.String())
+	if ce.Optional {
+		out.WriteString("?.")
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(arguments, ", "))
 	out.WriteString(")")
@@ -263,22 +518,93 @@ func (ce *CallExpression) String() string {
 	return out.String()
 }
 
+// SplitArguments separates the positional arguments of the call from the named
+// ones, which the parser only accepts after all positional ones
+func (ce *CallExpression) SplitArguments() ([]Expression, []*NamedArgument) {
+	for i, argument := range ce.Arguments {
+		if _, ok := argument.(*NamedArgument); !ok {
+			continue
+		}
+
+		named := []*NamedArgument{}
+		for _, argument := range ce.Arguments[i:] {
+			named = append(named, argument.(*NamedArgument))
+		}
+		return ce.Arguments[:i], named
+	}
+
+	return ce.Arguments, nil
+}
+
+// SpreadExpression passes the elements of an array as separate arguments of a
+// call, like `f(...args)`
+type SpreadExpression struct {
+	Token token.Token // the ... token
+	Value Expression
+}
+
+func (se *SpreadExpression) expressionNode()          {}
+func (se *SpreadExpression) TokenLiteral() string     { return se.Token.Literal }
+func (se *SpreadExpression) Position() token.Position { return se.Token.Position }
+func (se *SpreadExpression) String() string           { return "..." + se.Value.String() }
+
+// NamedArgument passes Value to the parameter called Name, like the `y: 3` in
+// `f(1, y: 3)`
+type NamedArgument struct {
+	Token token.Token // the name
+	Name  *Identifier
+	Value Expression
+}
+
+func (na *NamedArgument) expressionNode()          {}
+func (na *NamedArgument) TokenLiteral() string     { return na.Token.Literal }
+func (na *NamedArgument) Position() token.Position { return na.Token.Position }
+func (na *NamedArgument) String() string           { return na.Name.String() + ": " + na.Value.String() }
+
 type StringLiteral struct {
 	Token token.Token
 	Value string
 }
 
-func (sl *StringLiteral) expressionNode()      {}
-func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
-func (sl *StringLiteral) String() string       { return sl.Token.Literal }
+func (sl *StringLiteral) expressionNode()          {}
+func (sl *StringLiteral) TokenLiteral() string     { return sl.Token.Literal }
+func (sl *StringLiteral) Position() token.Position { return sl.Token.Position }
+func (sl *StringLiteral) String() string           { return sl.Token.Literal }
+
+// InterpolatedString is a string with embedded expressions like "a ${x} b",
+// its parts are StringLiterals for the text and arbitrary expressions in between
+type InterpolatedString struct {
+	Token token.Token // the STRINGHEAD token
+	Parts []Expression
+}
+
+func (is *InterpolatedString) expressionNode()          {}
+func (is *InterpolatedString) TokenLiteral() string     { return is.Token.Literal }
+func (is *InterpolatedString) Position() token.Position { return is.Token.Position }
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("\"")
+	for _, part := range is.Parts {
+		if literal, ok := part.(*StringLiteral); ok {
+			out.WriteString(literal.Value)
+		} else {
+			out.WriteString("${" + part.String() + "}")
+		}
+	}
+	out.WriteString("\"")
+
+	return out.String()
+}
 
 type ArrayLiteral struct {
 	Token    token.Token // the '[' token
 	Elements []Expression
 }
 
-func (al *ArrayLiteral) expressionNode()      {}
-func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) expressionNode()          {}
+func (al *ArrayLiteral) TokenLiteral() string     { return al.Token.Literal }
+func (al *ArrayLiteral) Position() token.Position { return al.Token.Position }
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
 
@@ -295,18 +621,23 @@ func (al *ArrayLiteral) String() string {
 }
 
 type IndexExpression struct {
-	Token token.Token // the '[' token
-	Left  Expression
-	Index Expression
+	Token    token.Token // the '[' token, or ?[ or ?. for an optional index
+	Left     Expression
+	Index    Expression
+	Optional bool // `a?[i]` gives null instead of indexing a when it is null
 }
 
-func (ix *IndexExpression) expressionNode()      {}
-func (ix *IndexExpression) TokenLiteral() string { return ix.Token.Literal }
+func (ix *IndexExpression) expressionNode()          {}
+func (ix *IndexExpression) TokenLiteral() string     { return ix.Token.Literal }
+func (ix *IndexExpression) Position() token.Position { return ix.Token.Position }
 func (ix *IndexExpression) String() string {
 	var out bytes.Buffer
 
 	out.WriteString("(")
 	out.WriteString(ix.Left.String())
+	if ix.Optional {
+		out.WriteString("?")
+	}
 	out.WriteString("[")
 	out.WriteString(ix.Index.String())
 	out.WriteString("])")
@@ -314,13 +645,68 @@ func (ix *IndexExpression) String() string {
 	return out.String()
 }
 
+// AssignExpression rebinds an existing name. Operator is "=", or "+=" and
+// "-=" to combine the current value with the new one
+type AssignExpression struct {
+	Token    token.Token // the assignment operator token
+	Name     *Identifier
+	Operator string
+	Value    Expression
+}
+
+func (ae *AssignExpression) expressionNode()          {}
+func (ae *AssignExpression) TokenLiteral() string     { return ae.Token.Literal }
+func (ae *AssignExpression) Position() token.Position { return ae.Token.Position }
+func (ae *AssignExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ae.Name.String())
+	out.WriteString(" ")
+	out.WriteString(ae.Operator)
+	out.WriteString(" ")
+	out.WriteString(ae.Value.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// IndexAssignExpression stores into an element of an array or a hash, e.g. `arr[i] = v`
+type IndexAssignExpression struct {
+	Token    token.Token // the assignment operator token
+	Left     Expression
+	Index    Expression
+	Operator string
+	Value    Expression
+}
+
+func (ia *IndexAssignExpression) expressionNode()          {}
+func (ia *IndexAssignExpression) TokenLiteral() string     { return ia.Token.Literal }
+func (ia *IndexAssignExpression) Position() token.Position { return ia.Token.Position }
+func (ia *IndexAssignExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ia.Left.String())
+	out.WriteString("[")
+	out.WriteString(ia.Index.String())
+	out.WriteString("] ")
+	out.WriteString(ia.Operator)
+	out.WriteString(" ")
+	out.WriteString(ia.Value.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
 type HashLiteral struct {
 	Token token.Token // the '{' token
 	Pairs map[Expression]Expression
 }
 
-func (hl *HashLiteral) expressionNode()      {}
-func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) expressionNode()          {}
+func (hl *HashLiteral) TokenLiteral() string     { return hl.Token.Literal }
+func (hl *HashLiteral) Position() token.Position { return hl.Token.Position }
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
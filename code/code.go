@@ -48,6 +48,45 @@ const (
 	OpPop
 	OpJumpNotTruthy
 	OpJump
+	OpGetGlobal
+	OpSetGlobal
+	OpCall
+	OpReturnValue
+	OpReturn
+	OpGetLocal
+	OpSetLocal
+	OpClosure
+	OpGetFree
+	OpCurrentClosure
+	OpArray
+	OpHash
+	OpIndex
+	OpGetBuiltin
+	OpIterInit
+	OpIterNext
+	OpDup
+	OpSetFree
+	OpSetIndex
+	OpMod
+	OpLessThan
+	OpGreaterThanOrEqual
+	OpLessThanOrEqual
+	OpPlus
+	OpInterpolate
+	OpJumpNull
+	OpJumpTable
+	OpMatchArray
+	OpMatchHash
+	OpUnpackArray
+	OpUnpackHash
+	OpSpread
+	OpCallSpread
+	OpEnterLoop
+	OpUnwindLoop
+	OpLeaveLoop
+	OpCaptureLocal
+	OpCaptureFree
+	OpCallNamed
 )
 
 type Definition struct {
@@ -56,22 +95,61 @@ type Definition struct {
 }
 
 var definitions = map[Opcode]*Definition{
-	OpConstant:      {"OpConstant", []int{2}},      // OpConstant definiton: push a constant (single operand, which is 2 bytes long) to the stack
-	OpTrue:          {"OpTrue", []int{}},           // OpTrue: push a boolean object representing true value onto the stack (no operands)
-	OpFalse:         {"OpFalse", []int{}},          // OpFalse: push a boolean object representing false value onto the stack (no operands)
-	OpNull:          {"OpNull", []int{}},           // OpNull: push a null object onto the stack (no operands)
-	OpAdd:           {"OpAdd", []int{}},            // OpAdd: pop the two topmost stack items, add them, and push the result (no operands)
-	OpSub:           {"OpSub", []int{}},            // OpSub: pop the two topmost stack items, subtract them, and push the result (no operands)
-	OpMul:           {"OpMul", []int{}},            // OpMul: pop the two topmost stack items, multiply them, and push the result (no operands)
-	OpDiv:           {"OpDiv", []int{}},            // OpDiv: pop the two topmost stack items, divide them, and push the result (no operands)
-	OpEqual:         {"OpEqual", []int{}},          // OpEqual: pop the two topmost stack items, compare them, and push the boolean result (no operands)
-	OpNotEqual:      {"OpNotEqual", []int{}},       // OpNotEqual: pop the two topmost stack items, compare them, and push the boolean result (no operands)
-	OpGreaterThan:   {"OpGreaterThan", []int{}},    // OpGreaterThan: pop the two topmost stack items, compare them, and push the boolean result (no operands)
-	OpMinus:         {"OpMinus", []int{}},          // OpMinus: pop the topmost stack item, and push it's negated value back (no operands)
-	OpBang:          {"OpBang", []int{}},           // OpBang: pop the topmost stack item, and push it's negated value back (no operands)
-	OpPop:           {"OpPop", []int{}},            // OpPop: pop the topmost element off the stack
-	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}}, // OpJumpNotTruthy: pop the topmost element off the stack and jump to the address specified as operand if the stack element was truthy (which is 2 bytes long)
-	OpJump:          {"OpJump", []int{2}},          // OpJump: jump to the address specified as operand (which is 2 bytes long)
+	OpConstant:           {"OpConstant", []int{2}},          // OpConstant definiton: push a constant (single operand, which is 2 bytes long) to the stack
+	OpTrue:               {"OpTrue", []int{}},               // OpTrue: push a boolean object representing true value onto the stack (no operands)
+	OpFalse:              {"OpFalse", []int{}},              // OpFalse: push a boolean object representing false value onto the stack (no operands)
+	OpNull:               {"OpNull", []int{}},               // OpNull: push a null object onto the stack (no operands)
+	OpAdd:                {"OpAdd", []int{}},                // OpAdd: pop the two topmost stack items, add them, and push the result (no operands)
+	OpSub:                {"OpSub", []int{}},                // OpSub: pop the two topmost stack items, subtract them, and push the result (no operands)
+	OpMul:                {"OpMul", []int{}},                // OpMul: pop the two topmost stack items, multiply them, and push the result (no operands)
+	OpDiv:                {"OpDiv", []int{}},                // OpDiv: pop the two topmost stack items, divide them, and push the result (no operands)
+	OpEqual:              {"OpEqual", []int{}},              // OpEqual: pop the two topmost stack items, compare them, and push the boolean result (no operands)
+	OpNotEqual:           {"OpNotEqual", []int{}},           // OpNotEqual: pop the two topmost stack items, compare them, and push the boolean result (no operands)
+	OpGreaterThan:        {"OpGreaterThan", []int{}},        // OpGreaterThan: pop the two topmost stack items, compare them, and push the boolean result (no operands)
+	OpMinus:              {"OpMinus", []int{}},              // OpMinus: pop the topmost stack item, and push it's negated value back (no operands)
+	OpBang:               {"OpBang", []int{}},               // OpBang: pop the topmost stack item, and push it's negated value back (no operands)
+	OpPop:                {"OpPop", []int{}},                // OpPop: pop the topmost element off the stack
+	OpJumpNotTruthy:      {"OpJumpNotTruthy", []int{2}},     // OpJumpNotTruthy: pop the topmost element off the stack and jump to the address specified as operand if the stack element was truthy (which is 2 bytes long)
+	OpJump:               {"OpJump", []int{2}},              // OpJump: jump to the address specified as operand (which is 2 bytes long)
+	OpGetGlobal:          {"OpGetGlobal", []int{2}},         // OpGetGlobal: push the global binding stored at the index specified as operand (which is 2 bytes long)
+	OpSetGlobal:          {"OpSetGlobal", []int{2}},         // OpSetGlobal: pop the topmost element off the stack and store it as a global binding at the index specified as operand (which is 2 bytes long)
+	OpCall:               {"OpCall", []int{1}},              // OpCall: call the function sitting below its arguments on the stack, the operand is the number of arguments (which is 1 byte long)
+	OpReturnValue:        {"OpReturnValue", []int{}},        // OpReturnValue: return from the current function with the topmost stack element as the return value (no operands)
+	OpReturn:             {"OpReturn", []int{}},             // OpReturn: return from the current function without a return value, implicitly returning null (no operands)
+	OpGetLocal:           {"OpGetLocal", []int{1}},          // OpGetLocal: push the local binding stored at the index specified as operand (which is 1 byte long)
+	OpSetLocal:           {"OpSetLocal", []int{1}},          // OpSetLocal: pop the topmost element off the stack and store it as a local binding at the index specified as operand (which is 1 byte long)
+	OpClosure:            {"OpClosure", []int{2, 1}},        // OpClosure: wrap the compiled function constant at the first operand (2 bytes long) in a closure, capturing as many free variables off the stack as the second operand says (1 byte long), as cells
+	OpGetFree:            {"OpGetFree", []int{1}},           // OpGetFree: push the value of the free variable of the current closure stored at the index specified as operand (which is 1 byte long)
+	OpCurrentClosure:     {"OpCurrentClosure", []int{}},     // OpCurrentClosure: push the closure currently being executed, used for recursive self-references (no operands)
+	OpArray:              {"OpArray", []int{2}},             // OpArray: pop as many elements as the operand says (which is 2 bytes long) and push an array built from them
+	OpHash:               {"OpHash", []int{2}},              // OpHash: pop as many keys and values as the operand says (which is 2 bytes long) and push a hash built from them
+	OpIndex:              {"OpIndex", []int{}},              // OpIndex: pop the index and the indexed object off the stack, and push the element found (no operands)
+	OpGetBuiltin:         {"OpGetBuiltin", []int{1}},        // OpGetBuiltin: push the builtin function registered at the index specified as operand (which is 1 byte long)
+	OpIterInit:           {"OpIterInit", []int{}},           // OpIterInit: pop an array, string or hash off the stack and push an iterator over it (no operands)
+	OpDup:                {"OpDup", []int{1}},               // OpDup: push copies of as many topmost stack elements as the operand says (which is 1 byte long), keeping their order
+	OpSetFree:            {"OpSetFree", []int{1}},           // OpSetFree: pop the topmost element off the stack and store it in the cell of the free variable of the current closure at the index specified as operand (which is 1 byte long), where the enclosing function sees it too
+	OpSetIndex:           {"OpSetIndex", []int{}},           // OpSetIndex: pop a value, an index and an array or hash off the stack, store the value at the index and push it back (no operands)
+	OpMod:                {"OpMod", []int{}},                // OpMod: pop the two topmost stack items, take the remainder of dividing them, and push the result (no operands)
+	OpLessThan:           {"OpLessThan", []int{}},           // OpLessThan: pop the two topmost stack items, compare them, and push the boolean result (no operands)
+	OpGreaterThanOrEqual: {"OpGreaterThanOrEqual", []int{}}, // OpGreaterThanOrEqual: pop the two topmost stack items, compare them, and push the boolean result (no operands)
+	OpLessThanOrEqual:    {"OpLessThanOrEqual", []int{}},    // OpLessThanOrEqual: pop the two topmost stack items, compare them, and push the boolean result (no operands)
+	OpJumpNull:           {"OpJumpNull", []int{2}},          // OpJumpNull: pop the topmost element off the stack and jump to the address specified as operand if the stack element was null (which is 2 bytes long)
+	OpJumpTable:          {"OpJumpTable", []int{2}},         // OpJumpTable: pop the topmost element off the stack and look it up in the hash constant at the index specified as operand (which is 2 bytes long), jumping to the address found if there is one
+	OpMatchArray:         {"OpMatchArray", []int{2, 1}},     // OpMatchArray: pop the topmost element off the stack and push whether it is an array with as many elements as the first operand says (which is 2 bytes long), or at least as many if the second operand (which is 1 byte long) is 1
+	OpMatchHash:          {"OpMatchHash", []int{2}},         // OpMatchHash: pop as many keys as the operand says (which is 2 bytes long) and then a value, and push whether the value is a hash with all of the keys
+	OpUnpackArray:        {"OpUnpackArray", []int{2, 1}},    // OpUnpackArray: pop an array and push as many of its elements as the first operand says (which is 2 bytes long), the first one last and null for the missing ones, after an array of the remaining ones if the second operand (which is 1 byte long) is 1
+	OpUnpackHash:         {"OpUnpackHash", []int{2, 1}},     // OpUnpackHash: pop as many keys as the first operand says (which is 2 bytes long) and then a hash, and push the values of the keys, the first one last and null for the missing ones, after a hash of the other pairs if the second operand (which is 1 byte long) is 1
+	OpSpread:             {"OpSpread", []int{}},             // OpSpread: pop an array and append its elements to the array below it on the stack (no operands)
+	OpCallSpread:         {"OpCallSpread", []int{}},         // OpCallSpread: pop an array and call the function sitting below it with its elements as arguments (no operands)
+	OpInterpolate:        {"OpInterpolate", []int{2}},       // OpInterpolate: pop as many values as the operand says (which is 2 bytes long) and push a string joining how each of them is displayed
+	OpPlus:               {"OpPlus", []int{}},               // OpPlus: pop the topmost stack item, check it is a number and push it back (no operands)
+	OpIterNext:           {"OpIterNext", []int{2, 1}},       // OpIterNext: advance the iterator on top of the stack and push the next element, or the next key and value if the second operand (1 byte long) is 2; once exhausted, pop the iterator and jump to the address specified as first operand (2 bytes long)
+	OpEnterLoop:          {"OpEnterLoop", []int{}},          // OpEnterLoop: remember the height of the stack as the one of the loop starting (no operands)
+	OpUnwindLoop:         {"OpUnwindLoop", []int{}},         // OpUnwindLoop: drop what was pushed since the innermost loop started, such as the operands pending when a `break` or `continue` is in an expression (no operands)
+	OpLeaveLoop:          {"OpLeaveLoop", []int{}},          // OpLeaveLoop: forget the stack height of the innermost loop (no operands)
+	OpCaptureLocal:       {"OpCaptureLocal", []int{1}},      // OpCaptureLocal: move the local binding at the index specified as operand (which is 1 byte long) into a cell, unless it is in one already, and push the cell for OpClosure
+	OpCaptureFree:        {"OpCaptureFree", []int{1}},       // OpCaptureFree: push the cell of the free variable of the current closure at the index specified as operand (which is 1 byte long) for OpClosure
+	OpCallNamed:          {"OpCallNamed", []int{1, 2}},      // OpCallNamed: call the function sitting below its arguments on the stack, the first operand is the number of arguments (which is 1 byte long) and the second one the index of the array constant naming the last ones (which is 2 bytes long)
 }
 
 func Lookup(op byte) (*Definition, error) {
@@ -103,6 +181,8 @@ func Make(op Opcode, operands ...int) []byte {
 		switch width {
 		case 2:
 			binary.BigEndian.PutUint16(instruction[offset:], uint16(operand))
+		case 1:
+			instruction[offset] = byte(operand)
 		}
 		offset += width
 	}
@@ -118,6 +198,8 @@ func ReadOperands(definition *Definition, instructions Instructions) ([]int, int
 		switch width {
 		case 2:
 			operands[i] = int(ReadUint16(instructions[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(instructions[offset:]))
 		}
 
 		offset += width
@@ -130,6 +212,10 @@ func ReadUint16(Instructions Instructions) uint16 {
 	return binary.BigEndian.Uint16(Instructions)
 }
 
+func ReadUint8(instructions Instructions) uint8 {
+	return uint8(instructions[0])
+}
+
 func (ins Instructions) fmtInstruction(definition *Definition, operands []int) string {
 	operandCount := len(definition.OperandWidths)
 
@@ -142,6 +228,8 @@ func (ins Instructions) fmtInstruction(definition *Definition, operands []int) s
 		return definition.Name
 	case 1:
 		return fmt.Sprintf("%s %d", definition.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", definition.Name, operands[0], operands[1])
 	}
 
 	return fmt.Sprintf("ERROR: number of operands for %s\n", definition.Name)
@@ -5,25 +5,79 @@ import (
 	"monkey-lang/ast"
 	"monkey-lang/code"
 	"monkey-lang/object"
+	"monkey-lang/token"
+	"sort"
 )
 
 type Compiler struct {
-	instructions        code.Instructions  // holds the generated bytecode
-	constants           []object.Object    // constants pool
-	lastInstruction     EmittedInstruction // the very last instruction we emitted
-	previousInstruction EmittedInstruction // the instruction we emitted prior to `lastInstruction`
+	constants   []object.Object // constants pool
+	symbolTable *SymbolTable    // the bindings visible from the current scope
+
+	scopes     []CompilationScope // one scope per function body being compiled, the main program is scope 0
+	scopeIndex int
+
+	position token.Position // the source position of the node being compiled
+}
+
+type CompilationScope struct {
+	instructions        code.Instructions      // holds the generated bytecode
+	lastInstruction     EmittedInstruction     // the very last instruction we emitted
+	previousInstruction EmittedInstruction     // the instruction we emitted prior to `lastInstruction`
+	sourceMap           map[int]token.Position // the source position of every emitted instruction
+	loops               []*Loop                // the loops enclosing the code being compiled, innermost last
 }
 
+type Loop struct {
+	startPos   int   // where `continue` jumps to
+	breakJumps []int // the `OpJump`s emitted for `break`, patched once the end of the loop is known
+	iterator   bool  // whether an iterator sits on the stack while the loop runs, which `break` has to pop
+}
+
+// The limits of what the one byte operands of OpCall, OpGetLocal and the like,
+// OpGetFree and the like, OpClosure and OpGetBuiltin can address
+const (
+	maxArguments     = 255
+	maxLocals        = 256
+	maxFreeVariables = 255
+	maxBuiltins      = 256
+)
+
 func New() *Compiler {
-	return &Compiler{
+	mainScope := CompilationScope{
 		instructions:        code.Instructions{},
-		constants:           []object.Object{},
 		lastInstruction:     EmittedInstruction{},
 		previousInstruction: EmittedInstruction{},
+		sourceMap:           make(map[int]token.Position),
+	}
+
+	symbolTable := NewSymbolTable()
+	for i, definition := range object.Builtins {
+		symbolTable.DefineBuiltin(i, definition.Name)
+	}
+
+	return &Compiler{
+		constants:   []object.Object{},
+		symbolTable: symbolTable,
+		scopes:      []CompilationScope{mainScope},
+		scopeIndex:  0,
 	}
 }
 
+func NewWithState(symbolTable *SymbolTable, constants []object.Object) *Compiler {
+	compiler := New()
+	compiler.symbolTable = symbolTable
+	compiler.constants = constants
+	return compiler
+}
+
 func (c *Compiler) Compile(node ast.Node) error {
+	// Instructions emitted while compiling the node are attributed to its position
+	if position := node.Position(); position.IsValid() {
+		previous := c.position
+		c.position = position
+		defer func() { c.position = previous }()
+	}
+
 	switch node := node.(type) {
 	case *ast.Program:
 		for _, statement := range node.Statements {
@@ -41,19 +95,8 @@ func (c *Compiler) Compile(node ast.Node) error {
 		c.emit(code.OpPop)
 
 	case *ast.InfixExpression:
-		if node.Operator == "<" {
-			err := c.Compile(node.Right)
-			if err != nil {
-				return err
-			}
-
-			err = c.Compile(node.Left)
-			if err != nil {
-				return err
-			}
-
-			c.emit(code.OpGreaterThan)
-			return nil
+		if node.Operator == "&&" || node.Operator == "||" || node.Operator == "??" {
+			return c.compileLogicalExpression(node)
 		}
 
 		err := c.Compile(node.Left)
@@ -75,8 +118,16 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.emit(code.OpMul)
 		case "/":
 			c.emit(code.OpDiv)
+		case "%":
+			c.emit(code.OpMod)
 		case ">":
 			c.emit(code.OpGreaterThan)
+		case "<":
+			c.emit(code.OpLessThan)
+		case ">=":
+			c.emit(code.OpGreaterThanOrEqual)
+		case "<=":
+			c.emit(code.OpLessThanOrEqual)
 		case "==":
 			c.emit(code.OpEqual)
 		case "!=":
@@ -97,6 +148,8 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.emit(code.OpBang)
 		case "-":
 			c.emit(code.OpMinus)
+		case "+":
+			c.emit(code.OpPlus)
 		default:
 			return fmt.Errorf("unknown operator: %s", node.Operator)
 		}
@@ -105,6 +158,134 @@ func (c *Compiler) Compile(node ast.Node) error {
 		integer := &object.Integer{Value: node.Value}
 		c.emit(code.OpConstant, c.addConstant(integer))
 
+	case *ast.FloatLiteral:
+		float := &object.Float{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(float))
+
+	case *ast.StringLiteral:
+		str := &object.String{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(str))
+
+	case *ast.InterpolatedString:
+		for _, part := range node.Parts {
+			err := c.Compile(part)
+			if err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpInterpolate, len(node.Parts))
+
+	case *ast.ArrayLiteral:
+		for _, element := range node.Elements {
+			err := c.Compile(element)
+			if err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpArray, len(node.Elements))
+
+	case *ast.HashLiteral:
+		keys := []ast.Expression{}
+		for key := range node.Pairs {
+			keys = append(keys, key)
+		}
+
+		// Go maps have no stable iteration order, sort the keys to emit deterministic bytecode
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+
+		for _, key := range keys {
+			err := c.Compile(key)
+			if err != nil {
+				return err
+			}
+
+			err = c.Compile(node.Pairs[key])
+			if err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpHash, len(node.Pairs)*2)
+
+	case *ast.IndexExpression:
+		err := c.Compile(node.Left)
+		if err != nil {
+			return err
+		}
+
+		skipJumpPos := c.emitOptionalJump(node.Optional)
+
+		err = c.Compile(node.Index)
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpIndex)
+
+		if node.Optional {
+			c.changeOperand(skipJumpPos, len(c.currentInstructions()))
+		}
+
+	case *ast.AssignExpression:
+		symbol, ok := c.symbolTable.Resolve(node.Name.Value)
+		if !ok || symbol.Scope == BuiltinScope {
+			return fmt.Errorf("assignment to undeclared variable %s", node.Name.Value)
+		}
+		if symbol.Scope == FunctionScope {
+			return fmt.Errorf("cannot assign to %s within its own body", node.Name.Value)
+		}
+		if symbol.Constant {
+			return fmt.Errorf("cannot assign to constant %s", node.Name.Value)
+		}
+
+		if node.Operator != "=" {
+			c.loadSymbol(symbol)
+		}
+
+		err := c.Compile(node.Value)
+		if err != nil {
+			return err
+		}
+
+		if node.Operator != "=" {
+			c.emit(assignmentOpcode(node.Operator))
+		}
+
+		// Assignments are expressions, keep a copy of the value as their result
+		c.emit(code.OpDup, 1)
+		c.storeSymbol(symbol)
+	case *ast.IndexAssignExpression:
+		err := c.Compile(node.Left)
+		if err != nil {
+			return err
+		}
+
+		err = c.Compile(node.Index)
+		if err != nil {
+			return err
+		}
+
+		if node.Operator != "=" {
+			c.emit(code.OpDup, 2)
+			c.emit(code.OpIndex)
+		}
+
+		err = c.Compile(node.Value)
+		if err != nil {
+			return err
+		}
+
+		if node.Operator != "=" {
+			c.emit(assignmentOpcode(node.Operator))
+		}
+
+		c.emit(code.OpSetIndex)
+	case *ast.NullLiteral:
+		c.emit(code.OpNull)
 	case *ast.Boolean:
 		if node.Value {
 			c.emit(code.OpTrue)
@@ -125,14 +306,12 @@ func (c *Compiler) Compile(node ast.Node) error {
 			return err
 		}
 
-		if c.lastInstructionIsPop() {
-			c.removeLastInstruction()
-		}
+		c.keepBlockValue()
 
 		// Emit an `OpJump` with a bogus value
 		jumpPos := c.emit(code.OpJump, 9999)
 
-		afterConsequencePos := len(c.instructions)
+		afterConsequencePos := len(c.currentInstructions())
 		c.changeOperand(jumpNotTruthyPos, afterConsequencePos)
 
 		if node.Alternative == nil {
@@ -143,13 +322,13 @@ func (c *Compiler) Compile(node ast.Node) error {
 				return err
 			}
 
-			if c.lastInstructionIsPop() {
-				c.removeLastInstruction()
-			}
+			c.keepBlockValue()
 		}
 
-		afterAlternativePos := len(c.instructions)
+		afterAlternativePos := len(c.currentInstructions())
 		c.changeOperand(jumpPos, afterAlternativePos)
+	case *ast.MatchExpression:
+		return c.compileMatchExpression(node)
 	case *ast.BlockStatement:
 		for _, s := range node.Statements {
 			err := c.Compile(s)
@@ -157,21 +336,362 @@ func (c *Compiler) Compile(node ast.Node) error {
 				return err
 			}
 		}
+	case *ast.WhileStatement:
+		c.emit(code.OpEnterLoop)
+
+		startPos := len(c.currentInstructions())
+
+		err := c.Compile(node.Condition)
+		if err != nil {
+			return err
+		}
+
+		// Emit an `OpJumpNotTruthy` with a bogus operand
+		jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+		loop := c.enterLoop(startPos)
+
+		err = c.Compile(node.Body)
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpJump, startPos)
+
+		c.leaveLoop()
+
+		afterLoopPos := c.emit(code.OpLeaveLoop)
+		c.changeOperand(jumpNotTruthyPos, afterLoopPos)
+		for _, breakPos := range loop.breakJumps {
+			c.changeOperand(breakPos, afterLoopPos)
+		}
+
+		// Loops evaluate to null, popped like the value of an expression statement
+		c.emit(code.OpNull)
+		c.emit(code.OpPop)
+	case *ast.ForStatement:
+		err := c.Compile(node.Iterable)
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpIterInit)
+		c.emit(code.OpEnterLoop)
+
+		startPos := len(c.currentInstructions())
+
+		// Emit an `OpIterNext` with a bogus jump operand, patched once the end of the loop is known
+		numValues := 1
+		if node.Key != nil {
+			numValues = 2
+		}
+		iterNextPos := c.emit(code.OpIterNext, 9999, numValues)
+
+		// The value sits on top of the key
+		valueSymbol, err := c.defineBinding(node.Value.Value, false)
+		if err != nil {
+			return err
+		}
+		c.storeSymbol(valueSymbol)
+		if node.Key != nil {
+			keySymbol, err := c.defineBinding(node.Key.Value, false)
+			if err != nil {
+				return err
+			}
+			c.storeSymbol(keySymbol)
+		}
+
+		loop := c.enterLoop(startPos)
+		loop.iterator = true
+
+		err = c.Compile(node.Body)
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpJump, startPos)
+
+		c.leaveLoop()
+
+		afterLoopPos := c.emit(code.OpLeaveLoop)
+		c.changeOperand(iterNextPos, afterLoopPos, numValues)
+		for _, breakPos := range loop.breakJumps {
+			c.changeOperand(breakPos, afterLoopPos)
+		}
+
+		// Loops evaluate to null, popped like the value of an expression statement
+		c.emit(code.OpNull)
+		c.emit(code.OpPop)
+	case *ast.BreakStatement:
+		loop := c.currentLoop()
+		if loop == nil {
+			return fmt.Errorf("break outside of loop")
+		}
+
+		// `break` may sit in an expression whose operands are still on the stack
+		c.emit(code.OpUnwindLoop)
+		if loop.iterator {
+			c.emit(code.OpPop)
+		}
+
+		// Emit an `OpJump` with a bogus value, patched when the loop is done
+		loop.breakJumps = append(loop.breakJumps, c.emit(code.OpJump, 9999))
+	case *ast.ContinueStatement:
+		loop := c.currentLoop()
+		if loop == nil {
+			return fmt.Errorf("continue outside of loop")
+		}
+
+		c.emit(code.OpUnwindLoop)
+		c.emit(code.OpJump, loop.startPos)
+	case *ast.LetStatement:
+		err := c.Compile(node.Value)
+		if err != nil {
+			return err
+		}
+
+		if node.Pattern != nil {
+			return c.compileDestructuring(node.Pattern, node.Constant)
+		}
+
+		symbol, err := c.defineBinding(node.Name.Value, node.Constant)
+		if err != nil {
+			return err
+		}
+		c.storeSymbol(symbol)
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(node.Value)
+		if !ok {
+			return fmt.Errorf("undefined variable %s", node.Value)
+		}
+		if symbol.Scope == BuiltinScope && symbol.Index >= maxBuiltins {
+			return fmt.Errorf("too many builtins: %s is number %d, the limit is %d", node.Value, symbol.Index+1, maxBuiltins)
+		}
+
+		c.loadSymbol(symbol)
+	case *ast.FunctionExpression:
+		c.enterScope()
+
+		if node.Name != "" {
+			c.symbolTable.DefineFunctionName(node.Name)
+		}
+
+		for _, parameter := range node.Parameters {
+			c.symbolTable.Define(parameter.Value)
+		}
+		if node.Rest != nil {
+			c.symbolTable.Define(node.Rest.Value)
+		}
+
+		err := c.compileDefaults(node)
+		if err != nil {
+			return err
+		}
+
+		err = c.Compile(node.Body)
+		if err != nil {
+			return err
+		}
+
+		// The value of the last expression statement is the implicit return value
+		if c.lastInstructionIs(code.OpPop) {
+			c.replaceLastPopWithReturn()
+		}
+		if !c.lastInstructionIs(code.OpReturnValue) {
+			c.emit(code.OpReturn)
+		}
+
+		freeSymbols := c.symbolTable.FreeSymbols
+		numLocals := c.symbolTable.numDefinitions
+		localNames := c.symbolTable.Names()
+		if numLocals > maxLocals {
+			return fmt.Errorf("too many local bindings in function: %d, the limit is %d", numLocals, maxLocals)
+		}
+		if len(freeSymbols) > maxFreeVariables {
+			return fmt.Errorf("too many free variables in function: %d, the limit is %d", len(freeSymbols), maxFreeVariables)
+		}
+		sourceMap := c.scopes[c.scopeIndex].sourceMap
+		instructions := c.leaveScope()
+
+		// Push the cells of the captured variables so OpClosure can collect them
+		freeNames := make([]string, len(freeSymbols))
+		for i, symbol := range freeSymbols {
+			c.captureSymbol(symbol)
+			freeNames[i] = symbol.Name
+		}
+
+		compiledFunction := &object.CompiledFunction{
+			Instructions:  instructions,
+			NumLocals:     numLocals,
+			NumParameters: len(node.Parameters),
+			NumRequired:   node.NumRequired(),
+			HasRest:       node.Rest != nil,
+			Name:          node.Name,
+			LocalNames:    localNames,
+			FreeNames:     freeNames,
+			SourceMap:     sourceMap,
+		}
+		c.emit(code.OpClosure, c.addConstant(compiledFunction), len(freeSymbols))
+	case *ast.ReturnStatement:
+		err := c.Compile(node.ReturnValue)
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpReturnValue)
+	case *ast.CallExpression:
+		err := c.Compile(node.Function)
+		if err != nil {
+			return err
+		}
+
+		skipJumpPos := c.emitOptionalJump(node.Optional)
+
+		if hasSpread(node.Arguments) {
+			err := c.compileSpreadArguments(node.Arguments)
+			if err != nil {
+				return err
+			}
+
+			c.emit(code.OpCallSpread)
+		} else {
+			if len(node.Arguments) > maxArguments {
+				return fmt.Errorf("too many arguments in call: %d, the limit is %d", len(node.Arguments), maxArguments)
+			}
+
+			positional, named := node.SplitArguments()
+			for _, argument := range positional {
+				err := c.Compile(argument)
+				if err != nil {
+					return err
+				}
+			}
+
+			if len(named) == 0 {
+				c.emit(code.OpCall, len(node.Arguments))
+			} else {
+				// The VM matches the names to the parameters of the function it calls
+				names := &object.Array{Elements: []object.Object{}}
+				for _, argument := range named {
+					err := c.Compile(argument.Value)
+					if err != nil {
+						return err
+					}
+					names.Elements = append(names.Elements, &object.String{Value: argument.Name.Value})
+				}
+
+				c.emit(code.OpCallNamed, len(node.Arguments), c.addConstant(names))
+			}
+		}
+
+		if node.Optional {
+			c.changeOperand(skipJumpPos, len(c.currentInstructions()))
+		}
+	}
+
+	return nil
+}
+
+// compileDefaults emits the start of a function, which sets the parameters
+// with a default value to it when their argument is missing or null. The VM
+// passes null for missing arguments
+func (c *Compiler) compileDefaults(function *ast.FunctionExpression) error {
+	for i, defaultValue := range function.Defaults {
+		if defaultValue == nil {
+			continue
+		}
+
+		c.emit(code.OpGetLocal, i)
+		defaultJumpPos := c.emit(code.OpJumpNull, 9999)
+		endJumpPos := c.emit(code.OpJump, 9999)
+
+		// A default can refer to the parameters before it, but not to itself or
+		// the ones after it
+		later := []string{}
+		for _, parameter := range function.Parameters[i:] {
+			later = append(later, parameter.Value)
+		}
+		if function.Rest != nil {
+			later = append(later, function.Rest.Value)
+		}
+		hidden := c.symbolTable.hide(later)
+
+		c.changeOperand(defaultJumpPos, len(c.currentInstructions()))
+		err := c.Compile(defaultValue)
+		c.symbolTable.unhide(hidden)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpSetLocal, i)
+
+		c.changeOperand(endJumpPos, len(c.currentInstructions()))
+	}
+
+	return nil
+}
+
+// compileSpreadArguments collects the arguments of a call into an array for
+// OpCallSpread, appending the elements of each spread array and each run of
+// other arguments with OpSpread
+func (c *Compiler) compileSpreadArguments(arguments []ast.Expression) error {
+	c.emit(code.OpArray, 0)
+
+	numPending := 0
+	for _, argument := range arguments {
+		spread, ok := argument.(*ast.SpreadExpression)
+		if !ok {
+			err := c.Compile(argument)
+			if err != nil {
+				return err
+			}
+			numPending++
+			continue
+		}
+
+		if numPending > 0 {
+			c.emit(code.OpArray, numPending)
+			c.emit(code.OpSpread)
+			numPending = 0
+		}
+
+		err := c.Compile(spread.Value)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpSpread)
+	}
+
+	if numPending > 0 {
+		c.emit(code.OpArray, numPending)
+		c.emit(code.OpSpread)
 	}
 
 	return nil
 }
 
+func hasSpread(arguments []ast.Expression) bool {
+	for _, argument := range arguments {
+		if _, ok := argument.(*ast.SpreadExpression); ok {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Compiler) Bytecode() *Bytecode {
 	return &Bytecode{
-		Instructions: c.instructions,
+		Instructions: c.currentInstructions(),
 		Constants:    c.constants,
+		SourceMap:    c.scopes[c.scopeIndex].sourceMap,
+		GlobalNames:  c.symbolTable.Names(),
 	}
 }
 
 type Bytecode struct {
-	Instructions code.Instructions // the instructions generated by Compiler
-	Constants    []object.Object   // the constants evaluated by Compiler
+	Instructions code.Instructions      // the instructions generated by Compiler
+	Constants    []object.Object        // the constants evaluated by Compiler
+	SourceMap    map[int]token.Position // the source positions of the instructions
+	GlobalNames  []string               // the name of each global, by index
 }
 
 type EmittedInstruction struct {
@@ -189,42 +709,523 @@ func (c *Compiler) emit(op code.Opcode, operands ...int) int {
 	position := c.addInstruction(instruction)
 
 	c.setLastInstruction(op, position)
+	c.scopes[c.scopeIndex].sourceMap[position] = c.position
 
 	return position
 }
 
 func (c *Compiler) setLastInstruction(op code.Opcode, position int) {
-	previous := c.lastInstruction
+	previous := c.scopes[c.scopeIndex].lastInstruction
 	last := EmittedInstruction{Opcode: op, Position: position}
 
-	c.previousInstruction = previous
-	c.lastInstruction = last
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
 }
 
 func (c *Compiler) addInstruction(instruction []byte) int {
-	posNewInstruction := len(c.instructions)
-	c.instructions = append(c.instructions, instruction...)
+	posNewInstruction := len(c.currentInstructions())
+	c.scopes[c.scopeIndex].instructions = append(c.currentInstructions(), instruction...)
 	return posNewInstruction
 }
 
-func (c *Compiler) lastInstructionIsPop() bool {
-	return c.lastInstruction.Opcode == code.OpPop
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
 }
 
 func (c *Compiler) removeLastInstruction() {
-	c.instructions = c.instructions[:c.lastInstruction.Position]
-	c.lastInstruction = c.previousInstruction
+	last := c.scopes[c.scopeIndex].lastInstruction
+	previous := c.scopes[c.scopeIndex].previousInstruction
+
+	c.scopes[c.scopeIndex].instructions = c.currentInstructions()[:last.Position]
+	c.scopes[c.scopeIndex].lastInstruction = previous
+	delete(c.scopes[c.scopeIndex].sourceMap, last.Position)
 }
 
 func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	instructions := c.currentInstructions()
+
 	for i := 0; i < len(newInstruction); i++ {
-		c.instructions[pos+i] = newInstruction[i]
+		instructions[pos+i] = newInstruction[i]
 	}
 }
 
-func (c *Compiler) changeOperand(opPos int, operand int) {
-	op := code.Opcode(c.instructions[opPos])
-	posNewInstruction := code.Make(op, operand)
+func (c *Compiler) changeOperand(opPos int, operands ...int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	posNewInstruction := code.Make(op, operands...)
 
 	c.replaceInstruction(opPos, posNewInstruction)
 }
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+// keepBlockValue leaves the value of the block just compiled on the stack,
+// blocks not ending in an expression evaluate to null
+func (c *Compiler) keepBlockValue() {
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastInstruction()
+	} else {
+		c.emit(code.OpNull)
+	}
+}
+
+func (c *Compiler) enterLoop(startPos int) *Loop {
+	loop := &Loop{startPos: startPos, breakJumps: []int{}}
+	c.scopes[c.scopeIndex].loops = append(c.scopes[c.scopeIndex].loops, loop)
+	return loop
+}
+
+func (c *Compiler) leaveLoop() {
+	loops := c.scopes[c.scopeIndex].loops
+	c.scopes[c.scopeIndex].loops = loops[:len(loops)-1]
+}
+
+func (c *Compiler) currentLoop() *Loop {
+	loops := c.scopes[c.scopeIndex].loops
+	if len(loops) == 0 {
+		return nil
+	}
+
+	return loops[len(loops)-1]
+}
+
+func (c *Compiler) enterScope() {
+	scope := CompilationScope{
+		instructions:        code.Instructions{},
+		lastInstruction:     EmittedInstruction{},
+		previousInstruction: EmittedInstruction{},
+		sourceMap:           make(map[int]token.Position),
+	}
+	c.scopes = append(c.scopes, scope)
+	c.scopeIndex++
+
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+
+	c.symbolTable = c.symbolTable.Outer
+
+	return instructions
+}
+
+// compileLogicalExpression leaves the left operand on the stack when it decides
+// the result, and only evaluates the right operand otherwise. For `??` that is
+// when the left operand isn't null
+func (c *Compiler) compileLogicalExpression(node *ast.InfixExpression) error {
+	err := c.Compile(node.Left)
+	if err != nil {
+		return err
+	}
+
+	c.emit(code.OpDup, 1)
+
+	// Emit jumps with bogus operands, patched once the end of the expression is known
+	var endJumpPos int
+	if node.Operator == "&&" {
+		endJumpPos = c.emit(code.OpJumpNotTruthy, 9999)
+	} else {
+		jumpOpcode := code.OpJumpNotTruthy
+		if node.Operator == "??" {
+			jumpOpcode = code.OpJumpNull
+		}
+
+		rightJumpPos := c.emit(jumpOpcode, 9999)
+		endJumpPos = c.emit(code.OpJump, 9999)
+		c.changeOperand(rightJumpPos, len(c.currentInstructions()))
+	}
+
+	c.emit(code.OpPop)
+
+	err = c.Compile(node.Right)
+	if err != nil {
+		return err
+	}
+
+	c.changeOperand(endJumpPos, len(c.currentInstructions()))
+
+	return nil
+}
+
+// emitOptionalJump emits the jump over the rest of an optional index or call,
+// taken with the null on the stack as the result when the value being indexed
+// or called is null. It returns the position of the jump for patching, or -1
+// when optional is false and nothing was emitted
+func (c *Compiler) emitOptionalJump(optional bool) int {
+	if !optional {
+		return -1
+	}
+
+	c.emit(code.OpDup, 1)
+	return c.emit(code.OpJumpNull, 9999)
+}
+
+// compileMatchExpression keeps the subject in a hidden variable and tests the
+// arms in order. Runs of arms with literal patterns are compiled into a single
+// `OpJumpTable`, other patterns are tested piece by piece and only bind their
+// variables once the whole pattern matched. Names defined by an arm are only
+// visible within it
+func (c *Compiler) compileMatchExpression(node *ast.MatchExpression) error {
+	err := c.Compile(node.Subject)
+	if err != nil {
+		return err
+	}
+
+	// `match` is a keyword, so this can't clash with user defined names
+	subject := c.symbolTable.Define("match")
+	c.storeSymbol(subject)
+
+	endJumps := []int{}
+	compileBody := func(body *ast.BlockStatement) error {
+		err := c.Compile(body)
+		if err != nil {
+			return err
+		}
+
+		c.keepBlockValue()
+		endJumps = append(endJumps, c.emit(code.OpJump, 9999))
+		return nil
+	}
+
+	for i := 0; i < len(node.Arms); {
+		if patternLiteral(node.Arms[i].Pattern) != nil {
+			table := &object.Hash{Pairs: map[object.HashKey]object.HashPair{}}
+
+			c.loadSymbol(subject)
+			c.emit(code.OpJumpTable, c.addConstant(table))
+			nextJumpPos := c.emit(code.OpJump, 9999)
+
+			for ; i < len(node.Arms) && patternLiteral(node.Arms[i].Pattern) != nil; i++ {
+				literal := patternLiteral(node.Arms[i].Pattern)
+
+				// Only the first of several arms with the same literal can match
+				hashKey := literal.(object.Hashable).HashKey()
+				if _, ok := table.Pairs[hashKey]; !ok {
+					target := &object.Integer{Value: int64(len(c.currentInstructions()))}
+					table.Pairs[hashKey] = object.HashPair{Key: literal, Value: target}
+				}
+
+				outerSymbols := c.symbolTable.snapshot()
+				err := compileBody(node.Arms[i].Body)
+				if err != nil {
+					return err
+				}
+				c.symbolTable.restore(outerSymbols)
+			}
+
+			c.changeOperand(nextJumpPos, len(c.currentInstructions()))
+			continue
+		}
+
+		arm := node.Arms[i]
+		failJumps := c.compilePatternTest(arm.Pattern, subject, nil)
+
+		outerSymbols := c.symbolTable.snapshot()
+		c.compilePatternBindings(arm.Pattern, subject, nil)
+
+		err := compileBody(arm.Body)
+		if err != nil {
+			return err
+		}
+		c.symbolTable.restore(outerSymbols)
+
+		for _, failJump := range failJumps {
+			c.changeOperand(failJump, len(c.currentInstructions()))
+		}
+		i++
+	}
+
+	c.emit(code.OpNull)
+
+	for _, endJump := range endJumps {
+		c.changeOperand(endJump, len(c.currentInstructions()))
+	}
+
+	return nil
+}
+
+// compilePatternTest emits the checks of whether the part of subject at path
+// matches pattern, returning the positions of the jumps taken when it doesn't
+func (c *Compiler) compilePatternTest(pattern ast.Expression, subject Symbol, path []object.Object) []int {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		return nil
+	case *ast.NullLiteral:
+		c.loadPath(subject, path)
+		c.emit(code.OpNull)
+		c.emit(code.OpEqual)
+		return []int{c.emit(code.OpJumpNotTruthy, 9999)}
+	case *ast.ArrayPattern:
+		c.loadPath(subject, path)
+		c.emit(code.OpMatchArray, len(pattern.Elements), hasRest(pattern.Rest))
+		failJumps := []int{c.emit(code.OpJumpNotTruthy, 9999)}
+
+		for i, element := range pattern.Elements {
+			elementPath := append(path[:len(path):len(path)], &object.Integer{Value: int64(i)})
+			failJumps = append(failJumps, c.compilePatternTest(element, subject, elementPath)...)
+		}
+		return failJumps
+	case *ast.HashPattern:
+		c.loadPath(subject, path)
+		for _, key := range pattern.Keys {
+			c.emit(code.OpConstant, c.addConstant(patternLiteral(key)))
+		}
+		c.emit(code.OpMatchHash, len(pattern.Keys))
+		failJumps := []int{c.emit(code.OpJumpNotTruthy, 9999)}
+
+		for i, value := range pattern.Values {
+			valuePath := append(path[:len(path):len(path)], patternLiteral(pattern.Keys[i]))
+			failJumps = append(failJumps, c.compilePatternTest(value, subject, valuePath)...)
+		}
+		return failJumps
+	default:
+		// A one entry jump table skips the jump to the next arm when the literal matches
+		literal := patternLiteral(pattern)
+		table := &object.Hash{Pairs: map[object.HashKey]object.HashPair{}}
+
+		c.loadPath(subject, path)
+		c.emit(code.OpJumpTable, c.addConstant(table))
+		failJump := c.emit(code.OpJump, 9999)
+
+		target := &object.Integer{Value: int64(len(c.currentInstructions()))}
+		table.Pairs[literal.(object.Hashable).HashKey()] = object.HashPair{Key: literal, Value: target}
+		return []int{failJump}
+	}
+}
+
+// compilePatternBindings emits the assignments of the variables in a pattern
+// that matched the part of subject at path
+func (c *Compiler) compilePatternBindings(pattern ast.Expression, subject Symbol, path []object.Object) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if pattern.Value == "_" {
+			return
+		}
+
+		c.loadPath(subject, path)
+		c.storeSymbol(c.symbolTable.Define(pattern.Value))
+	case *ast.ArrayPattern:
+		for i, element := range pattern.Elements {
+			elementPath := append(path[:len(path):len(path)], &object.Integer{Value: int64(i)})
+			c.compilePatternBindings(element, subject, elementPath)
+		}
+
+		if pattern.Rest != nil {
+			c.loadPath(subject, path)
+			c.emit(code.OpUnpackArray, len(pattern.Elements), 1)
+			c.compileRestBinding(pattern.Rest, len(pattern.Elements))
+		}
+	case *ast.HashPattern:
+		for i, value := range pattern.Values {
+			valuePath := append(path[:len(path):len(path)], patternLiteral(pattern.Keys[i]))
+			c.compilePatternBindings(value, subject, valuePath)
+		}
+
+		if pattern.Rest != nil {
+			c.loadPath(subject, path)
+			c.emitHashUnpacking(pattern)
+			c.compileRestBinding(pattern.Rest, len(pattern.Keys))
+		}
+	}
+}
+
+// compileRestBinding binds the rest of an unpacked array or hash, which is
+// below the numAbove values unpacked before it
+func (c *Compiler) compileRestBinding(rest *ast.Identifier, numAbove int) {
+	for i := 0; i < numAbove; i++ {
+		c.emit(code.OpPop)
+	}
+
+	if rest.Value == "_" {
+		c.emit(code.OpPop)
+	} else {
+		c.storeSymbol(c.symbolTable.Define(rest.Value))
+	}
+}
+
+// compileDestructuring emits the bindings of the names in the pattern of a let
+// statement to the parts of the value on top of the stack, which it consumes
+func (c *Compiler) compileDestructuring(pattern ast.Expression, constant bool) error {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if pattern.Value == "_" {
+			c.emit(code.OpPop)
+			return nil
+		}
+
+		symbol, err := c.defineBinding(pattern.Value, constant)
+		if err != nil {
+			return err
+		}
+		c.storeSymbol(symbol)
+	case *ast.DefaultPattern:
+		c.emit(code.OpDup, 1)
+		defaultJumpPos := c.emit(code.OpJumpNull, 9999)
+		endJumpPos := c.emit(code.OpJump, 9999)
+
+		c.changeOperand(defaultJumpPos, len(c.currentInstructions()))
+		c.emit(code.OpPop)
+		err := c.Compile(pattern.Default)
+		if err != nil {
+			return err
+		}
+
+		c.changeOperand(endJumpPos, len(c.currentInstructions()))
+		return c.compileDestructuring(pattern.Target, constant)
+	case *ast.ArrayPattern:
+		c.emit(code.OpUnpackArray, len(pattern.Elements), hasRest(pattern.Rest))
+
+		for _, element := range pattern.Elements {
+			err := c.compileDestructuring(element, constant)
+			if err != nil {
+				return err
+			}
+		}
+
+		if pattern.Rest != nil {
+			return c.compileDestructuring(pattern.Rest, constant)
+		}
+	case *ast.HashPattern:
+		c.emitHashUnpacking(pattern)
+
+		for _, value := range pattern.Values {
+			err := c.compileDestructuring(value, constant)
+			if err != nil {
+				return err
+			}
+		}
+
+		if pattern.Rest != nil {
+			return c.compileDestructuring(pattern.Rest, constant)
+		}
+	}
+
+	return nil
+}
+
+func (c *Compiler) emitHashUnpacking(pattern *ast.HashPattern) {
+	for _, key := range pattern.Keys {
+		c.emit(code.OpConstant, c.addConstant(patternLiteral(key)))
+	}
+
+	c.emit(code.OpUnpackHash, len(pattern.Keys), hasRest(pattern.Rest))
+}
+
+// defineBinding defines name for a let or const statement. Constants can't be
+// redeclared in their own scope, only shadowed in enclosed ones
+func (c *Compiler) defineBinding(name string, constant bool) (Symbol, error) {
+	if c.symbolTable.definesConstant(name) {
+		return Symbol{}, fmt.Errorf("cannot redeclare constant %s", name)
+	}
+
+	if constant {
+		return c.symbolTable.DefineConstant(name), nil
+	}
+	return c.symbolTable.Define(name), nil
+}
+
+// hasRest returns the operand telling OpMatchArray and the unpacking opcodes whether there is a rest element
+func hasRest(rest *ast.Identifier) int {
+	if rest == nil {
+		return 0
+	}
+	return 1
+}
+
+// loadPath pushes the part of subject reached by indexing it with each key in path
+func (c *Compiler) loadPath(subject Symbol, path []object.Object) {
+	c.loadSymbol(subject)
+
+	for _, key := range path {
+		c.emit(code.OpConstant, c.addConstant(key))
+		c.emit(code.OpIndex)
+	}
+}
+
+// patternLiteral returns the value of a pattern that is a hashable literal, or nil
+func patternLiteral(pattern ast.Expression) object.Object {
+	switch pattern := pattern.(type) {
+	case *ast.IntegerLiteral:
+		return &object.Integer{Value: pattern.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: pattern.Value}
+	case *ast.StringLiteral:
+		return &object.String{Value: pattern.Value}
+	case *ast.Boolean:
+		return &object.Boolean{Value: pattern.Value}
+	case *ast.PrefixExpression:
+		switch literal := patternLiteral(pattern.Right).(type) {
+		case *object.Integer:
+			return &object.Integer{Value: -literal.Value}
+		case *object.Float:
+			return &object.Float{Value: -literal.Value}
+		}
+	}
+
+	return nil
+}
+
+func (c *Compiler) storeSymbol(symbol Symbol) {
+	switch symbol.Scope {
+	case GlobalScope:
+		c.emit(code.OpSetGlobal, symbol.Index)
+	case LocalScope:
+		c.emit(code.OpSetLocal, symbol.Index)
+	case FreeScope:
+		c.emit(code.OpSetFree, symbol.Index)
+	}
+}
+
+// assignmentOpcode returns the operation a compound assignment operator applies
+func assignmentOpcode(operator string) code.Opcode {
+	if operator == "-=" {
+		return code.OpSub
+	}
+
+	return code.OpAdd
+}
+
+func (c *Compiler) loadSymbol(symbol Symbol) {
+	switch symbol.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, symbol.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, symbol.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, symbol.Index)
+	case FunctionScope:
+		c.emit(code.OpCurrentClosure)
+	case BuiltinScope:
+		c.emit(code.OpGetBuiltin, symbol.Index)
+	}
+}
+
+// captureSymbol pushes the cell a closure shares with the enclosing function
+// for symbol, which is one of the closure's free variables
+func (c *Compiler) captureSymbol(symbol Symbol) {
+	switch symbol.Scope {
+	case LocalScope:
+		c.emit(code.OpCaptureLocal, symbol.Index)
+	case FreeScope:
+		c.emit(code.OpCaptureFree, symbol.Index)
+	default:
+		// The closure being defined can't be reassigned, so a cell of its own will do
+		c.loadSymbol(symbol)
+	}
+}
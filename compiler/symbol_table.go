@@ -0,0 +1,191 @@
+package compiler
+
+import "monkey-lang/object"
+
+type SymbolScope string
+
+const (
+	GlobalScope   SymbolScope = "GLOBAL"
+	LocalScope    SymbolScope = "LOCAL"
+	FreeScope     SymbolScope = "FREE"
+	FunctionScope SymbolScope = "FUNCTION"
+	BuiltinScope  SymbolScope = "BUILTIN"
+
+	hiddenScope SymbolScope = "HIDDEN" // a name that isn't visible yet, see hide
+)
+
+type Symbol struct {
+	Name     string
+	Scope    SymbolScope
+	Index    int
+	Constant bool // bound with const, so it can't be reassigned
+}
+
+type SymbolTable struct {
+	Outer *SymbolTable // the enclosing symbol table, nil for the global one
+
+	// The symbols of enclosing scopes referenced from this one, in the order they were captured
+	FreeSymbols []Symbol
+
+	store          map[string]Symbol
+	numDefinitions int
+	names          []string // the name of each definition, by index
+}
+
+func NewSymbolTable() *SymbolTable {
+	s := make(map[string]Symbol)
+	free := []Symbol{}
+	return &SymbolTable{store: s, FreeSymbols: free}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+func (s *SymbolTable) Define(name string) Symbol {
+	symbol := Symbol{Name: name, Index: s.numDefinitions}
+	if s.Outer == nil {
+		symbol.Scope = GlobalScope
+	} else {
+		symbol.Scope = LocalScope
+	}
+
+	s.store[name] = symbol
+	s.numDefinitions++
+	s.names = append(s.names, name)
+	return symbol
+}
+
+// Names returns the name of each definition made in this table, by index,
+// including the ones no longer in scope
+func (s *SymbolTable) Names() []string {
+	return s.names
+}
+
+// Clone returns a copy of the table that can be defined into without changing
+// the original, e.g. to drop the definitions of code that fails to compile
+func (s *SymbolTable) Clone() *SymbolTable {
+	clone := &SymbolTable{
+		Outer:          s.Outer,
+		FreeSymbols:    append([]Symbol{}, s.FreeSymbols...),
+		store:          s.snapshot(),
+		numDefinitions: s.numDefinitions,
+		names:          append([]string{}, s.names...),
+	}
+	return clone
+}
+
+// DropUnsetGlobals forgets the global definitions whose slot in globals was
+// never set, putting back the definition of the same name in previous if
+// there is one. A session does this after running a line, which may have
+// failed before setting all the names it defines
+func (s *SymbolTable) DropUnsetGlobals(previous *SymbolTable, globals []object.Object) {
+	for name, symbol := range s.store {
+		if symbol.Scope != GlobalScope || globals[symbol.Index] != nil {
+			continue
+		}
+
+		if original, ok := previous.store[name]; ok {
+			s.store[name] = original
+		} else {
+			delete(s.store, name)
+		}
+	}
+}
+
+// DefineConstant defines name like Define, as a binding that can't be reassigned
+func (s *SymbolTable) DefineConstant(name string) Symbol {
+	symbol := s.Define(name)
+	symbol.Constant = true
+	s.store[name] = symbol
+	return symbol
+}
+
+// definesConstant reports whether name is a constant defined in this table,
+// rather than one captured from an enclosing table
+func (s *SymbolTable) definesConstant(name string) bool {
+	symbol, ok := s.store[name]
+	return ok && symbol.Constant && symbol.Scope != FreeScope
+}
+
+// DefineBuiltin binds name to the builtin function at index in object.Builtins
+func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
+	symbol := Symbol{Name: name, Index: index, Scope: BuiltinScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+// DefineFunctionName binds the name of the function being compiled, so that
+// the function can refer to itself without capturing itself as a free variable
+func (s *SymbolTable) DefineFunctionName(name string) Symbol {
+	symbol := Symbol{Name: name, Index: 0, Scope: FunctionScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+// snapshot returns the names currently defined in this table, to be put back
+// by restore when leaving a block whose definitions must not outlive it. The
+// slots of those definitions stay allocated
+func (s *SymbolTable) snapshot() map[string]Symbol {
+	store := make(map[string]Symbol, len(s.store))
+	for name, symbol := range s.store {
+		store[name] = symbol
+	}
+	return store
+}
+
+func (s *SymbolTable) restore(snapshot map[string]Symbol) {
+	s.store = snapshot
+}
+
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	symbol, ok := s.store[name]
+	if ok && symbol.Scope == hiddenScope {
+		return Symbol{}, false
+	}
+	if !ok && s.Outer != nil {
+		symbol, ok = s.Outer.Resolve(name)
+		if !ok {
+			return symbol, ok
+		}
+
+		if symbol.Scope == GlobalScope || symbol.Scope == BuiltinScope {
+			return symbol, ok
+		}
+
+		// A local of an enclosing function has to be captured
+		return s.defineFree(symbol), true
+	}
+
+	return symbol, ok
+}
+
+// hide makes the names defined in this table unresolvable, without resolving
+// them in the enclosing tables instead, until unhide puts back the symbols it
+// returns
+func (s *SymbolTable) hide(names []string) map[string]Symbol {
+	hidden := make(map[string]Symbol, len(names))
+	for _, name := range names {
+		hidden[name] = s.store[name]
+		s.store[name] = Symbol{Name: name, Scope: hiddenScope}
+	}
+
+	return hidden
+}
+
+func (s *SymbolTable) unhide(hidden map[string]Symbol) {
+	for name, symbol := range hidden {
+		s.store[name] = symbol
+	}
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1, Scope: FreeScope, Constant: original.Constant}
+	s.store[original.Name] = symbol
+
+	return symbol
+}
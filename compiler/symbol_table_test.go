@@ -0,0 +1,271 @@
+package compiler
+
+import (
+	"monkey-lang/object"
+	"testing"
+)
+
+func TestDefine(t *testing.T) {
+	expected := map[string]Symbol{
+		"a": Symbol{Name: "a", Scope: GlobalScope, Index: 0},
+		"b": Symbol{Name: "b", Scope: GlobalScope, Index: 1},
+		"c": Symbol{Name: "c", Scope: LocalScope, Index: 0},
+		"d": Symbol{Name: "d", Scope: LocalScope, Index: 1},
+	}
+
+	global := NewSymbolTable()
+
+	a := global.Define("a")
+	if a != expected["a"] {
+		t.Errorf("expected a=%+v, actual=%+v", expected["a"], a)
+	}
+
+	b := global.Define("b")
+	if b != expected["b"] {
+		t.Errorf("expected b=%+v, actual=%+v", expected["b"], b)
+	}
+
+	local := NewEnclosedSymbolTable(global)
+
+	c := local.Define("c")
+	if c != expected["c"] {
+		t.Errorf("expected c=%+v, actual=%+v", expected["c"], c)
+	}
+
+	d := local.Define("d")
+	if d != expected["d"] {
+		t.Errorf("expected d=%+v, actual=%+v", expected["d"], d)
+	}
+}
+
+func TestResolveGlobal(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+	global.Define("b")
+
+	expected := []Symbol{
+		Symbol{Name: "a", Scope: GlobalScope, Index: 0},
+		Symbol{Name: "b", Scope: GlobalScope, Index: 1},
+	}
+
+	for _, symbol := range expected {
+		result, ok := global.Resolve(symbol.Name)
+		if !ok {
+			t.Errorf("name %s not resolvable", symbol.Name)
+			continue
+		}
+
+		if result != symbol {
+			t.Errorf("expected %s to resolve to %+v, actual=%+v", symbol.Name, symbol, result)
+		}
+	}
+}
+
+func TestResolveLocal(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+	global.Define("b")
+
+	local := NewEnclosedSymbolTable(global)
+	local.Define("c")
+	local.Define("d")
+
+	expected := []Symbol{
+		Symbol{Name: "a", Scope: GlobalScope, Index: 0},
+		Symbol{Name: "b", Scope: GlobalScope, Index: 1},
+		Symbol{Name: "c", Scope: LocalScope, Index: 0},
+		Symbol{Name: "d", Scope: LocalScope, Index: 1},
+	}
+
+	for _, symbol := range expected {
+		result, ok := local.Resolve(symbol.Name)
+		if !ok {
+			t.Errorf("name %s not resolvable", symbol.Name)
+			continue
+		}
+
+		if result != symbol {
+			t.Errorf("expected %s to resolve to %+v, actual=%+v", symbol.Name, symbol, result)
+		}
+	}
+}
+
+func TestResolveFree(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	firstLocal := NewEnclosedSymbolTable(global)
+	firstLocal.Define("b")
+
+	secondLocal := NewEnclosedSymbolTable(firstLocal)
+	secondLocal.Define("c")
+
+	expected := []Symbol{
+		Symbol{Name: "a", Scope: GlobalScope, Index: 0},
+		Symbol{Name: "b", Scope: FreeScope, Index: 0},
+		Symbol{Name: "c", Scope: LocalScope, Index: 0},
+	}
+
+	for _, symbol := range expected {
+		result, ok := secondLocal.Resolve(symbol.Name)
+		if !ok {
+			t.Errorf("name %s not resolvable", symbol.Name)
+			continue
+		}
+
+		if result != symbol {
+			t.Errorf("expected %s to resolve to %+v, actual=%+v", symbol.Name, symbol, result)
+		}
+	}
+
+	expectedFree := []Symbol{
+		Symbol{Name: "b", Scope: LocalScope, Index: 0},
+	}
+
+	if len(secondLocal.FreeSymbols) != len(expectedFree) {
+		t.Fatalf("wrong number of free symbols. expected=%d, actual=%d", len(expectedFree), len(secondLocal.FreeSymbols))
+	}
+
+	for i, symbol := range expectedFree {
+		if secondLocal.FreeSymbols[i] != symbol {
+			t.Errorf("wrong free symbol. expected=%+v, actual=%+v", symbol, secondLocal.FreeSymbols[i])
+		}
+	}
+}
+
+func TestResolveUnresolvableFree(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	local := NewEnclosedSymbolTable(global)
+
+	_, ok := local.Resolve("b")
+	if ok {
+		t.Errorf("name b resolved, but was expected not to")
+	}
+}
+
+func TestDefineAndResolveFunctionName(t *testing.T) {
+	global := NewSymbolTable()
+	local := NewEnclosedSymbolTable(global)
+	local.DefineFunctionName("a")
+
+	expected := Symbol{Name: "a", Scope: FunctionScope, Index: 0}
+
+	result, ok := local.Resolve(expected.Name)
+	if !ok {
+		t.Fatalf("function name %s not resolvable", expected.Name)
+	}
+
+	if result != expected {
+		t.Errorf("expected %s to resolve to %+v, actual=%+v", expected.Name, expected, result)
+	}
+}
+
+func TestDefineConstant(t *testing.T) {
+	global := NewSymbolTable()
+	global.DefineConstant("a")
+
+	local := NewEnclosedSymbolTable(global)
+	local.DefineConstant("b")
+
+	nested := NewEnclosedSymbolTable(local)
+
+	expected := []Symbol{
+		Symbol{Name: "a", Scope: GlobalScope, Index: 0, Constant: true},
+		Symbol{Name: "b", Scope: FreeScope, Index: 0, Constant: true},
+	}
+
+	for _, symbol := range expected {
+		result, ok := nested.Resolve(symbol.Name)
+		if !ok {
+			t.Errorf("name %s not resolvable", symbol.Name)
+			continue
+		}
+
+		if result != symbol {
+			t.Errorf("expected %s to resolve to %+v, actual=%+v", symbol.Name, symbol, result)
+		}
+	}
+
+	if !local.definesConstant("b") || nested.definesConstant("b") {
+		t.Errorf("only the table defining b should report it as its constant")
+	}
+}
+
+func TestCloneAndDropUnsetGlobals(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+	global.Define("b")
+
+	clone := global.Clone()
+	clone.Define("b")
+	clone.Define("c")
+	clone.Define("d")
+
+	if _, ok := global.Resolve("c"); ok {
+		t.Fatalf("defining in the clone changed the original")
+	}
+
+	// a and the new b were set, c and d were not
+	globals := []object.Object{&object.Integer{Value: 1}, nil, &object.Integer{Value: 2}, nil, nil}
+	clone.DropUnsetGlobals(global, globals)
+
+	expected := []Symbol{
+		Symbol{Name: "a", Scope: GlobalScope, Index: 0},
+		Symbol{Name: "b", Scope: GlobalScope, Index: 2},
+	}
+
+	for _, symbol := range expected {
+		result, ok := clone.Resolve(symbol.Name)
+		if !ok || result != symbol {
+			t.Errorf("expected %s to resolve to %+v, actual=%+v", symbol.Name, symbol, result)
+		}
+	}
+
+	for _, name := range []string{"c", "d"} {
+		if _, ok := clone.Resolve(name); ok {
+			t.Errorf("unset global %s was not dropped", name)
+		}
+	}
+
+	// An unset redefinition puts back the previous definition
+	again := clone.Clone()
+	again.Define("a")
+	again.DropUnsetGlobals(clone, append(globals, nil))
+
+	if result, _ := again.Resolve("a"); result.Index != 0 {
+		t.Errorf("expected a to be restored to index 0, got=%+v", result)
+	}
+}
+
+func TestDefineResolveBuiltins(t *testing.T) {
+	global := NewSymbolTable()
+	firstLocal := NewEnclosedSymbolTable(global)
+	secondLocal := NewEnclosedSymbolTable(firstLocal)
+
+	expected := []Symbol{
+		Symbol{Name: "a", Scope: BuiltinScope, Index: 0},
+		Symbol{Name: "c", Scope: BuiltinScope, Index: 1},
+		Symbol{Name: "e", Scope: BuiltinScope, Index: 2},
+		Symbol{Name: "f", Scope: BuiltinScope, Index: 3},
+	}
+
+	for i, symbol := range expected {
+		global.DefineBuiltin(i, symbol.Name)
+	}
+
+	for _, table := range []*SymbolTable{global, firstLocal, secondLocal} {
+		for _, symbol := range expected {
+			result, ok := table.Resolve(symbol.Name)
+			if !ok {
+				t.Errorf("name %s not resolvable", symbol.Name)
+				continue
+			}
+
+			if result != symbol {
+				t.Errorf("expected %s to resolve to %+v, actual=%+v", symbol.Name, symbol, result)
+			}
+		}
+	}
+}
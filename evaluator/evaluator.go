@@ -2,11 +2,25 @@ package evaluator
 
 import (
 	"fmt"
+	"io"
+	"math"
 	"monkey-lang/ast"
 	"monkey-lang/object"
+	"strings"
 )
 
 func Eval(node ast.Node, environment *object.Environment) object.Object {
+	result := eval(node, environment)
+
+	// Errors are located at the innermost node they were raised by
+	if err, ok := result.(*object.Error); ok && !err.Position.IsValid() {
+		err.Position = node.Position()
+	}
+
+	return result
+}
+
+func eval(node ast.Node, environment *object.Environment) object.Object {
 	switch node := node.(type) {
 	// Statements
 	case *ast.Program:
@@ -17,86 +31,157 @@ func Eval(node ast.Node, environment *object.Environment) object.Object {
 		return evalBlockStatement(node, environment)
 	case *ast.ReturnStatement:
 		value := Eval(node.ReturnValue, environment)
-		if isError(value) {
+		if isAbrupt(value) {
 			return value
 		}
 		return &object.ReturnValue{Value: value}
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, environment)
+	case *ast.ForStatement:
+		return evalForStatement(node, environment)
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
 	case *ast.LetStatement:
 		value := Eval(node.Value, environment)
-		if isError(value) {
+		if isAbrupt(value) {
 			return value
 		}
-		environment.Set(node.Name.Value, value)
+		if node.Pattern != nil {
+			return destructure(node.Pattern, value, node.Constant, environment)
+		}
+		return bind(node.Name.Value, value, node.Constant, environment)
 
 	// Expressions
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
+	case *ast.NullLiteral:
+		return NULL
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
+	case *ast.InterpolatedString:
+		parts := evalExpressions(node.Parts, environment)
+		if len(parts) == 1 && isAbrupt(parts[0]) {
+			return parts[0]
+		}
+		return evalInterpolatedString(parts)
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, environment)
-		if len(elements) == 1 && isError(elements[0]) {
+		if len(elements) == 1 && isAbrupt(elements[0]) {
 			return elements[0]
 		}
 		return &object.Array{Elements: elements}
 	case *ast.PrefixExpression:
 		right := Eval(node.Right, environment)
-		if isError(right) {
+		if isAbrupt(right) {
 			return right
 		}
-		return evalPrefixExpression(node.Operator, right)
+		return evalPrefixExpression(node.Operator, right, environment)
 	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" || node.Operator == "??" {
+			return evalLogicalExpression(node, environment)
+		}
+
 		left := Eval(node.Left, environment)
-		if isError(left) {
+		if isAbrupt(left) {
 			return left
 		}
 		right := Eval(node.Right, environment)
-		if isError(right) {
+		if isAbrupt(right) {
 			return right
 		}
-		return evalInfixExpression(node.Operator, left, right)
+		return evalInfixExpression(node.Operator, left, right, environment)
 	case *ast.IfExpression:
 		return evalIfExpression(node, environment)
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, environment)
 	case *ast.Identifier:
 		return evalIdentifier(node, environment)
 	case *ast.FunctionExpression:
-		parameters := node.Parameters
-		body := node.Body
-		return &object.Function{Parameters: parameters, Body: body, Environment: environment}
+		return &object.Function{
+			Parameters:  node.Parameters,
+			Defaults:    node.Defaults,
+			NumRequired: node.NumRequired(),
+			Rest:        node.Rest,
+			Body:        node.Body,
+			Environment: environment,
+			Name:        node.Name,
+		}
 	case *ast.CallExpression:
 		function := Eval(node.Function, environment)
-		if isError(function) {
+		if isAbrupt(function) {
 			return function
 		}
-		args := evalExpressions(node.Arguments, environment)
-		if len(args) == 1 && isError(args[0]) {
+		if node.Optional && function == NULL {
+			return NULL
+		}
+		positional, named := node.SplitArguments()
+		args := evalExpressions(positional, environment)
+		if len(args) == 1 && isAbrupt(args[0]) {
 			return args[0]
 		}
+		if len(named) > 0 {
+			args = evalNamedArguments(function, args, named, environment)
+			if len(args) == 1 && isAbrupt(args[0]) {
+				return args[0]
+			}
+		}
 
-		return applyFunction(function, args)
+		// The call never starts with the wrong number of arguments, so the
+		// function isn't part of the stack trace
+		if function, ok := function.(*object.Function); ok {
+			msg := object.CheckArity(function.NumRequired, len(function.Parameters), function.Rest != nil, len(args))
+			if msg != "" {
+				return newError("%s", msg)
+			}
+		}
+
+		result := applyFunction(function, args, environment.Output())
+
+		// Record the call of a Monkey function the error propagates through
+		if err, ok := result.(*object.Error); ok {
+			if function, ok := function.(*object.Function); ok {
+				frame := object.StackFrame{Function: function.Name, Position: node.Position()}
+				err.StackTrace = append(err.StackTrace, frame)
+			}
+		}
+
+		return result
 	case *ast.IndexExpression:
 		left := Eval(node.Left, environment)
-		if isError(left) {
+		if isAbrupt(left) {
 			return left
 		}
+		if node.Optional && left == NULL {
+			return NULL
+		}
 		index := Eval(node.Index, environment)
-		if isError(index) {
+		if isAbrupt(index) {
 			return index
 		}
 		return evalIndexExpression(left, index)
 	case *ast.HashLiteral:
 		return evalHashLiteral(node, environment)
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, environment)
+	case *ast.IndexAssignExpression:
+		return evalIndexAssignExpression(node, environment)
 	}
 
 	return nil
 }
 
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL     = &object.Null{}
+	TRUE     = &object.Boolean{Value: true}
+	FALSE    = &object.Boolean{Value: false}
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
 func evalProgram(program *ast.Program, environment *object.Environment) object.Object {
@@ -110,6 +195,8 @@ func evalProgram(program *ast.Program, environment *object.Environment) object.O
 			return result.Value
 		case *object.Error:
 			return result
+		case *object.Break, *object.Continue:
+			return newError("%s outside of loop", result.Inspect())
 		}
 	}
 
@@ -123,21 +210,25 @@ func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	return FALSE
 }
 
-func evalPrefixExpression(operator string, operand object.Object) object.Object {
+func evalPrefixExpression(operator string, operand object.Object, environment *object.Environment) object.Object {
 	switch operator {
 	case "!":
 		return evalBangOperatorExpression(operand)
 	case "-":
-		return evalMinusPrefixOperatorExpression(operand)
+		return evalMinusPrefixOperatorExpression(operand, environment)
+	case "+":
+		return evalPlusPrefixOperatorExpression(operand)
 	default:
 		return newError("unknown operator: %s%s", operator, operand.Type())
 	}
 }
 
-func evalInfixExpression(operator string, left, right object.Object) object.Object {
+func evalInfixExpression(operator string, left, right object.Object, environment *object.Environment) object.Object {
 	switch {
-	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
-		return evaluateIntegerInfixExpression(operator, left, right)
+	case object.IsInteger(left) && object.IsInteger(right):
+		return evaluateIntegerInfixExpression(operator, left, right, environment)
+	case object.IsNumber(left) && object.IsNumber(right):
+		return evaluateFloatInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evaluateStringInfixExpression(operator, left, right)
 	case operator == "==":
@@ -151,6 +242,25 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	}
 }
 
+// evalLogicalExpression only evaluates the right operand of `&&`, `||` and `??`
+// when the left one doesn't decide the result, which is the last operand evaluated
+func evalLogicalExpression(node *ast.InfixExpression, environment *object.Environment) object.Object {
+	left := Eval(node.Left, environment)
+	if isAbrupt(left) {
+		return left
+	}
+
+	if node.Operator == "??" {
+		if left != NULL {
+			return left
+		}
+	} else if isTruthy(left) == (node.Operator == "||") {
+		return left
+	}
+
+	return Eval(node.Right, environment)
+}
+
 func evalBangOperatorExpression(operand object.Object) object.Object {
 	switch operand {
 	case TRUE:
@@ -164,38 +274,77 @@ func evalBangOperatorExpression(operand object.Object) object.Object {
 	}
 }
 
-func evalMinusPrefixOperatorExpression(operand object.Object) object.Object {
-	if operand.Type() != object.INTEGER_OBJ {
+func evalMinusPrefixOperatorExpression(operand object.Object, environment *object.Environment) object.Object {
+	switch operand := operand.(type) {
+	case *object.Integer, *object.BigInteger:
+		return object.NegateInteger(operand, environment.IntegerOverflow())
+	case *object.Float:
+		return &object.Float{Value: -operand.Value}
+	default:
 		return newError("unknown operator: -%s", operand.Type())
 	}
+}
+
+func evalPlusPrefixOperatorExpression(operand object.Object) object.Object {
+	if !object.IsNumber(operand) {
+		return newError("unknown operator: +%s", operand.Type())
+	}
+
+	return operand
+}
 
-	value := operand.(*object.Integer).Value
-	return &object.Integer{Value: -value}
+func evaluateIntegerInfixExpression(operator string, left, right object.Object, environment *object.Environment) object.Object {
+	switch operator {
+	case "+", "-", "*", "/", "%":
+		return object.IntegerArithmetic(operator, left, right, environment.IntegerOverflow())
+	case "<":
+		return nativeBoolToBooleanObject(object.CompareIntegers(left, right) < 0)
+	case ">":
+		return nativeBoolToBooleanObject(object.CompareIntegers(left, right) > 0)
+	case "<=":
+		return nativeBoolToBooleanObject(object.CompareIntegers(left, right) <= 0)
+	case ">=":
+		return nativeBoolToBooleanObject(object.CompareIntegers(left, right) >= 0)
+	case "==":
+		return nativeBoolToBooleanObject(object.CompareIntegers(left, right) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(object.CompareIntegers(left, right) != 0)
+	default:
+		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	}
 }
 
-func evaluateIntegerInfixExpression(operator string, left, right object.Object) object.Object {
-	leftValue := left.(*object.Integer).Value
-	rightValue := right.(*object.Integer).Value
+// evaluateFloatInfixExpression handles arithmetic and comparisons between two
+// numbers of which at least one is a float, promoting the other one to a float
+func evaluateFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftValue := object.ToFloat(left)
+	rightValue := object.ToFloat(right)
 
 	switch operator {
 	case "+":
-		return &object.Integer{Value: leftValue + rightValue}
+		return &object.Float{Value: leftValue + rightValue}
 	case "-":
-		return &object.Integer{Value: leftValue - rightValue}
+		return &object.Float{Value: leftValue - rightValue}
 	case "*":
-		return &object.Integer{Value: leftValue * rightValue}
+		return &object.Float{Value: leftValue * rightValue}
 	case "/":
-		return &object.Integer{Value: leftValue / rightValue}
+		return &object.Float{Value: leftValue / rightValue}
+	case "%":
+		return &object.Float{Value: math.Mod(leftValue, rightValue)}
 	case "<":
 		return nativeBoolToBooleanObject(leftValue < rightValue)
 	case ">":
 		return nativeBoolToBooleanObject(leftValue > rightValue)
+	case "<=":
+		return nativeBoolToBooleanObject(leftValue <= rightValue)
+	case ">=":
+		return nativeBoolToBooleanObject(leftValue >= rightValue)
 	case "==":
 		return nativeBoolToBooleanObject(leftValue == rightValue)
 	case "!=":
 		return nativeBoolToBooleanObject(leftValue != rightValue)
 	default:
-		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
@@ -206,6 +355,10 @@ func evaluateStringInfixExpression(operator string, left, right object.Object) o
 	switch operator {
 	case "+":
 		return &object.String{Value: leftValue + rightValue}
+	case "==":
+		return nativeBoolToBooleanObject(leftValue == rightValue)
+	case "!=":
+		return nativeBoolToBooleanObject(leftValue != rightValue)
 	default:
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
@@ -214,7 +367,7 @@ func evaluateStringInfixExpression(operator string, left, right object.Object) o
 func evalIfExpression(ifExpression *ast.IfExpression, environment *object.Environment) object.Object {
 	condition := Eval(ifExpression.Condition, environment)
 
-	if isError(condition) {
+	if isAbrupt(condition) {
 		return condition
 	}
 
@@ -227,6 +380,171 @@ func evalIfExpression(ifExpression *ast.IfExpression, environment *object.Enviro
 	}
 }
 
+func evalMatchExpression(node *ast.MatchExpression, environment *object.Environment) object.Object {
+	subject := Eval(node.Subject, environment)
+	if isAbrupt(subject) {
+		return subject
+	}
+
+	for _, arm := range node.Arms {
+		bindings := map[string]object.Object{}
+		if !matchPattern(arm.Pattern, subject, bindings, environment) {
+			continue
+		}
+
+		// The names bound by the pattern, and defined in the body, are only visible within the arm
+		armEnvironment := object.NewEnclosedEnvironment(environment)
+		for name, value := range bindings {
+			armEnvironment.Set(name, value)
+		}
+
+		return Eval(arm.Body, armEnvironment)
+	}
+
+	return NULL
+}
+
+// matchPattern reports whether value matches pattern, collecting the values
+// bound by the identifiers in the pattern into bindings
+func matchPattern(pattern ast.Expression, value object.Object, bindings map[string]object.Object, environment *object.Environment) bool {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if pattern.Value != "_" {
+			bindings[pattern.Value] = value
+		}
+		return true
+	case *ast.NullLiteral:
+		return value == NULL
+	case *ast.ArrayPattern:
+		array, ok := value.(*object.Array)
+		if !ok || len(array.Elements) < len(pattern.Elements) ||
+			pattern.Rest == nil && len(array.Elements) > len(pattern.Elements) {
+			return false
+		}
+
+		for i, element := range pattern.Elements {
+			if !matchPattern(element, array.Elements[i], bindings, environment) {
+				return false
+			}
+		}
+
+		if pattern.Rest != nil {
+			matchPattern(pattern.Rest, array.From(len(pattern.Elements)), bindings, environment)
+		}
+		return true
+	case *ast.HashPattern:
+		hash, ok := value.(*object.Hash)
+		if !ok {
+			return false
+		}
+
+		hashKeys := patternHashKeys(pattern, environment)
+		for i, hashKey := range hashKeys {
+			pair, ok := hash.Pairs[hashKey]
+			if !ok || !matchPattern(pattern.Values[i], pair.Value, bindings, environment) {
+				return false
+			}
+		}
+
+		if pattern.Rest != nil {
+			matchPattern(pattern.Rest, hash.Without(hashKeys), bindings, environment)
+		}
+		return true
+	default:
+		// Literals match values of the same type only, so 1 doesn't match 1.0
+		literal, ok := Eval(pattern, environment).(object.Hashable)
+		hashable, isHashable := value.(object.Hashable)
+		return ok && isHashable && literal.HashKey() == hashable.HashKey()
+	}
+}
+
+// destructure binds the names in the pattern of a let statement to the parts
+// of value they stand for, missing parts being null unless a default is given
+func destructure(pattern ast.Expression, value object.Object, constant bool, environment *object.Environment) object.Object {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if pattern.Value != "_" {
+			return bind(pattern.Value, value, constant, environment)
+		}
+	case *ast.DefaultPattern:
+		if value == NULL {
+			value = Eval(pattern.Default, environment)
+			if isAbrupt(value) {
+				return value
+			}
+		}
+		return destructure(pattern.Target, value, constant, environment)
+	case *ast.ArrayPattern:
+		array, ok := value.(*object.Array)
+		if !ok {
+			return newError("cannot destructure %s as an array", value.Type())
+		}
+
+		for i, element := range pattern.Elements {
+			var elementValue object.Object = NULL
+			if i < len(array.Elements) {
+				elementValue = array.Elements[i]
+			}
+
+			if err := destructure(element, elementValue, constant, environment); err != nil {
+				return err
+			}
+		}
+
+		if pattern.Rest != nil {
+			return destructure(pattern.Rest, array.From(len(pattern.Elements)), constant, environment)
+		}
+	case *ast.HashPattern:
+		hash, ok := value.(*object.Hash)
+		if !ok {
+			return newError("cannot destructure %s as a hash", value.Type())
+		}
+
+		hashKeys := patternHashKeys(pattern, environment)
+		for i, hashKey := range hashKeys {
+			var pairValue object.Object = NULL
+			if pair, ok := hash.Pairs[hashKey]; ok {
+				pairValue = pair.Value
+			}
+
+			if err := destructure(pattern.Values[i], pairValue, constant, environment); err != nil {
+				return err
+			}
+		}
+
+		if pattern.Rest != nil {
+			return destructure(pattern.Rest, hash.Without(hashKeys), constant, environment)
+		}
+	}
+
+	return nil
+}
+
+// bind binds name for a let or const statement. Constants can't be redeclared
+// in their own scope, only shadowed in enclosed ones
+func bind(name string, value object.Object, constant bool, environment *object.Environment) object.Object {
+	if environment.IsConstant(name) {
+		return newError("cannot redeclare constant %s", name)
+	}
+
+	if constant {
+		environment.SetConstant(name, value)
+	} else {
+		environment.Set(name, value)
+	}
+
+	return nil
+}
+
+func patternHashKeys(pattern *ast.HashPattern, environment *object.Environment) []object.HashKey {
+	hashKeys := make([]object.HashKey, len(pattern.Keys))
+	for i, key := range pattern.Keys {
+		hashKeys[i] = Eval(key, environment).(object.Hashable).HashKey()
+	}
+
+	return hashKeys
+}
+
 func evalBlockStatement(block *ast.BlockStatement, environment *object.Environment) object.Object {
 	var result object.Object
 
@@ -234,8 +552,8 @@ func evalBlockStatement(block *ast.BlockStatement, environment *object.Environme
 		result = Eval(statement, environment)
 
 		if result != nil {
-			resultType := result.Type()
-			if resultType == object.RETURN_VALUE_OBJ || resultType == object.ERROR_OBJ {
+			switch result.Type() {
+			case object.RETURN_VALUE_OBJ, object.ERROR_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
 				return result
 			}
 		}
@@ -243,32 +561,166 @@ func evalBlockStatement(block *ast.BlockStatement, environment *object.Environme
 	return result
 }
 
+func evalWhileStatement(node *ast.WhileStatement, environment *object.Environment) object.Object {
+	for {
+		condition := Eval(node.Condition, environment)
+		if isAbrupt(condition) {
+			return condition
+		}
+
+		if !isTruthy(condition) {
+			return NULL
+		}
+
+		result := Eval(node.Body, environment)
+		if result != nil {
+			switch result.Type() {
+			case object.RETURN_VALUE_OBJ, object.ERROR_OBJ:
+				return result
+			case object.BREAK_OBJ:
+				return NULL
+			}
+		}
+	}
+}
+
+func evalForStatement(node *ast.ForStatement, environment *object.Environment) object.Object {
+	iterable := Eval(node.Iterable, environment)
+	if isAbrupt(iterable) {
+		return iterable
+	}
+
+	iterator, ok := object.NewIterator(iterable)
+	if !ok {
+		return newError("cannot iterate over %s", iterable.Type())
+	}
+
+	// Check the loop variables up front, so that looping over nothing fails too
+	for _, variable := range []*ast.Identifier{node.Value, node.Key} {
+		if variable != nil && environment.IsConstant(variable.Value) {
+			return newError("cannot redeclare constant %s", variable.Value)
+		}
+	}
+
+	for iterator.Next() {
+		var err object.Object
+		if node.Key != nil {
+			err = bind(node.Key.Value, iterator.Key(), false, environment)
+			if err == nil {
+				err = bind(node.Value.Value, iterator.Value(), false, environment)
+			}
+		} else {
+			err = bind(node.Value.Value, iterator.Element(), false, environment)
+		}
+		if err != nil {
+			return err
+		}
+
+		result := Eval(node.Body, environment)
+		if result != nil {
+			switch result.Type() {
+			case object.RETURN_VALUE_OBJ, object.ERROR_OBJ:
+				return result
+			case object.BREAK_OBJ:
+				return NULL
+			}
+		}
+	}
+
+	return NULL
+}
+
 func evalIdentifier(node *ast.Identifier, environment *object.Environment) object.Object {
 	if value, ok := environment.Get(node.Value); ok {
+		if value == nil {
+			// A parameter referred to by the default of an earlier one
+			return newError("identifier not found: %s", node.Value)
+		}
 		return value
 	}
 
-	if builtin, ok := builtins[node.Value]; ok {
+	if builtin := object.GetBuiltinByName(node.Value); builtin != nil {
 		return builtin
 	}
 
-	return newError("identifier not found: " + node.Value)
+	return newError("identifier not found: %s", node.Value)
 }
 
 func evalExpressions(expressions []ast.Expression, environment *object.Environment) []object.Object {
 	var result []object.Object
 
 	for _, expression := range expressions {
+		spread, isSpread := expression.(*ast.SpreadExpression)
+		if isSpread {
+			expression = spread.Value
+		}
+
 		evaluated := Eval(expression, environment)
-		if isError(evaluated) {
+		if isAbrupt(evaluated) {
 			return []object.Object{evaluated}
 		}
-		result = append(result, evaluated)
+
+		if !isSpread {
+			result = append(result, evaluated)
+			continue
+		}
+
+		array, ok := evaluated.(*object.Array)
+		if !ok {
+			return []object.Object{newError("cannot spread %s", evaluated.Type())}
+		}
+		result = append(result, array.Elements...)
 	}
 
 	return result
 }
 
+// evalNamedArguments evaluates the named arguments of a call and arranges
+// them after args at the positions of the parameters of fn they name
+func evalNamedArguments(fn object.Object, args []object.Object, named []*ast.NamedArgument, environment *object.Environment) []object.Object {
+	names := []string{}
+	values := []object.Object{}
+	for _, argument := range named {
+		value := Eval(argument.Value, environment)
+		if isAbrupt(value) {
+			return []object.Object{value}
+		}
+
+		names = append(names, argument.Name.Value)
+		values = append(values, value)
+	}
+
+	function, ok := fn.(*object.Function)
+	if !ok {
+		if fn.Type() == object.BUILTIN_FUNCTION_OBJ {
+			return []object.Object{newError("builtin functions don't take named arguments")}
+		}
+		return []object.Object{newError("Not a function: %s", fn.Type())}
+	}
+
+	parameters := []string{}
+	for _, parameter := range function.Parameters {
+		parameters = append(parameters, parameter.Value)
+	}
+
+	arranged, msg := object.ArrangeArguments(parameters, function.NumRequired, args, names, values, NULL)
+	if msg != "" {
+		return []object.Object{newError("%s", msg)}
+	}
+
+	return arranged
+}
+
+func evalInterpolatedString(parts []object.Object) object.Object {
+	var out strings.Builder
+
+	for _, part := range parts {
+		out.WriteString(part.Inspect())
+	}
+
+	return &object.String{Value: out.String()}
+}
+
 func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
@@ -293,12 +745,99 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 	return arrayObject.Elements[idx]
 }
 
+func evalAssignExpression(node *ast.AssignExpression, environment *object.Environment) object.Object {
+	current, ok := environment.Get(node.Name.Value)
+	if !ok || current == nil {
+		return newError("assignment to undeclared variable %s", node.Name.Value)
+	}
+
+	value := Eval(node.Value, environment)
+	if isAbrupt(value) {
+		return value
+	}
+
+	value = applyAssignmentOperator(node.Operator, current, value, environment)
+	if isAbrupt(value) {
+		return value
+	}
+
+	if _, err := environment.Assign(node.Name.Value, value); err != nil {
+		return newError("%s", err)
+	}
+	return value
+}
+
+func evalIndexAssignExpression(node *ast.IndexAssignExpression, environment *object.Environment) object.Object {
+	left := Eval(node.Left, environment)
+	if isAbrupt(left) {
+		return left
+	}
+
+	index := Eval(node.Index, environment)
+	if isAbrupt(index) {
+		return index
+	}
+
+	value := Eval(node.Value, environment)
+	if isAbrupt(value) {
+		return value
+	}
+
+	if node.Operator != "=" {
+		current := evalIndexExpression(left, index)
+		if isAbrupt(current) {
+			return current
+		}
+
+		value = applyAssignmentOperator(node.Operator, current, value, environment)
+		if isAbrupt(value) {
+			return value
+		}
+	}
+
+	if object.IsFrozen(left) {
+		return newError("cannot modify frozen %s", left.Type())
+	}
+
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		arrayObject := left.(*object.Array)
+		idx := index.(*object.Integer).Value
+		if idx < 0 || idx >= int64(len(arrayObject.Elements)) {
+			return newError("index out of range: %d", idx)
+		}
+
+		arrayObject.Elements[idx] = value
+	case left.Type() == object.HASH_OBJ:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("Unusable as hash key: %s", index.Type())
+		}
+
+		left.(*object.Hash).Pairs[key.HashKey()] = object.HashPair{Key: index, Value: value}
+	default:
+		return newError("Index assignment is not defined on type: %s", left.Type())
+	}
+
+	return value
+}
+
+// applyAssignmentOperator combines the current value of an assignment target
+// with the assigned one, e.g. `x += 1` adds 1 to the current value of x
+func applyAssignmentOperator(operator string, current, value object.Object, environment *object.Environment) object.Object {
+	if operator == "=" {
+		return value
+	}
+
+	return evalInfixExpression(strings.TrimSuffix(operator, "="), current, value, environment)
+}
+
 func evalHashLiteral(node *ast.HashLiteral, environment *object.Environment) object.Object {
 	pairs := make(map[object.HashKey]object.HashPair)
 
 	for keyNode, valueNode := range node.Pairs {
 		key := Eval(keyNode, environment)
-		if isError(key) {
+		if isAbrupt(key) {
 			return key
 		}
 
@@ -308,7 +847,7 @@ func evalHashLiteral(node *ast.HashLiteral, environment *object.Environment) obj
 		}
 
 		value := Eval(valueNode, environment)
-		if isError(value) {
+		if isAbrupt(value) {
 			return value
 		}
 
@@ -352,34 +891,85 @@ func newError(format string, a ...interface{}) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, a...)}
 }
 
-func isError(obj object.Object) bool {
-	if obj != nil {
-		return obj.Type() == object.ERROR_OBJ
+// isAbrupt reports whether obj ends the evaluation of the expression it
+// came from early: an error, or the return, break or continue of a block in
+// an if or match expression, which propagate to the enclosing function or loop
+func isAbrupt(obj object.Object) bool {
+	if obj == nil {
+		return false
+	}
+
+	switch obj.Type() {
+	case object.ERROR_OBJ, object.RETURN_VALUE_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
+		return true
+	default:
+		return false
 	}
-	return false
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+func applyFunction(fn object.Object, args []object.Object, out io.Writer) object.Object {
 	switch function := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnvironment(function, args)
+		extendedEnv, err := extendFunctionEnvironment(function, args)
+		if err != nil {
+			return err
+		}
+
 		evaluated := Eval(function.Body, extendedEnv)
+		if evaluated == BREAK || evaluated == CONTINUE {
+			return newError("%s outside of loop", evaluated.Inspect())
+		}
+		if evaluated == nil {
+			return NULL
+		}
 		return unwrapReturnValue(evaluated)
 	case *object.Builtin:
-		return function.Function(args...)
+		if result := function.Function(out, args...); result != nil {
+			return result
+		}
+		return NULL
 	default:
 		return newError("Not a function: %s", function.Type())
 	}
 }
 
-func extendFunctionEnvironment(fn *object.Function, args []object.Object) *object.Environment {
+// extendFunctionEnvironment binds the parameters of fn to args, evaluating the
+// defaults of missing or null arguments in order. A default can refer to the
+// parameters before it, but not to itself or the ones after it
+func extendFunctionEnvironment(fn *object.Function, args []object.Object) (*object.Environment, *object.Error) {
 	environment := object.NewEnclosedEnvironment(fn.Environment)
+	for _, param := range fn.Parameters {
+		environment.Declare(param.Value)
+	}
+	if fn.Rest != nil {
+		environment.Declare(fn.Rest.Value)
+	}
 
 	for paramIndex, param := range fn.Parameters {
-		environment.Set(param.Value, args[paramIndex])
+		var value object.Object = NULL
+		if paramIndex < len(args) {
+			value = args[paramIndex]
+		}
+
+		if value == NULL && fn.Defaults[paramIndex] != nil {
+			value = Eval(fn.Defaults[paramIndex], environment)
+			if err, ok := value.(*object.Error); ok {
+				return nil, err
+			}
+		}
+
+		environment.Set(param.Value, value)
+	}
+
+	if fn.Rest != nil {
+		rest := &object.Array{Elements: []object.Object{}}
+		if len(args) > len(fn.Parameters) {
+			rest.Elements = append(rest.Elements, args[len(fn.Parameters):]...)
+		}
+		environment.Set(fn.Rest.Value, rest)
 	}
 
-	return environment
+	return environment, nil
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -1,6 +1,7 @@
 package evaluator
 
 import (
+	"bytes"
 	"monkey-lang/lexer"
 	"monkey-lang/object"
 	"monkey-lang/parser"
@@ -27,6 +28,12 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"+5", 5},
+		{"-+5", -5},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"7 % -3", 1},
+		{"2 + 10 % 4 * 3", 8},
 	}
 
 	for _, tt := range tests {
@@ -35,6 +42,72 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestIntegerOverflow(t *testing.T) {
+	tests := []struct {
+		input    string
+		overflow object.IntegerOverflow
+		expected string
+	}{
+		{"9223372036854775807 + 1", object.OverflowError, "integer overflow: 9223372036854775807 + 1"},
+		{"-9223372036854775807 - 1 - 1", object.OverflowError, "integer overflow: -9223372036854775808 - 1"},
+		{"-(-9223372036854775807 - 1)", object.OverflowError, "integer overflow: -(-9223372036854775808)"},
+		{"let f = fn(x) { x * x }; f(4294967296)", object.OverflowError, "integer overflow: 4294967296 * 4294967296"},
+		{"1 / 0", object.OverflowError, "division by zero"},
+		{"let x = 5; x % 0", object.OverflowPromote, "division by zero"},
+		{"9223372036854775807 + 1", object.OverflowPromote, "9223372036854775808"},
+		{"let f = fn(x) { x * x }; f(4294967296)", object.OverflowPromote, "18446744073709551616"},
+		{"-(-9223372036854775807 - 1)", object.OverflowPromote, "9223372036854775808"},
+		{"(9223372036854775807 + 1) - 1", object.OverflowPromote, "9223372036854775807"},
+		{"9223372036854775807 + 1 > 9223372036854775807", object.OverflowPromote, "true"},
+		{"(9223372036854775807 + 1) * 0.5", object.OverflowPromote, "4.611686018427388e+18"},
+	}
+
+	for _, tt := range tests {
+		lexer := lexer.New(tt.input)
+		parser := parser.New(lexer)
+		program := parser.ParseProgram()
+		environment := object.NewEnvironment()
+		environment.SetIntegerOverflow(tt.overflow)
+
+		evaluated := Eval(program, environment)
+
+		var actual string
+		if err, ok := evaluated.(*object.Error); ok {
+			actual = err.Message
+		} else {
+			actual = evaluated.Inspect()
+		}
+
+		if actual != tt.expected {
+			t.Errorf("%q: want=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"1.5", 1.5},
+		{"-2.5", -2.5},
+		{"+2.5", 2.5},
+		{"1.5 + 1.5", 3},
+		{"1 + 0.5", 1.5},
+		{"0.5 * 4", 2},
+		{"1 / 4.0", 0.25},
+		{"5.5 % 2", 1.5},
+		{"2 - 0.5 * 3", 0.5},
+		{"1e2 + 1", 101},
+		{"let x = 1; x += 0.5; x", 1.5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -50,6 +123,26 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"1 != 2", true},
 		{"1 == 1", true},
 		{"1 != 1", false},
+		{"1.5 < 2", true},
+		{"2 > 1.5", true},
+		{"2 == 2.0", true},
+		{"2.5 != 2.5", false},
+		{"2.0 <= 2", true},
+		{"1 <= 2", true},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"3 >= 2", true},
+		{"let a = 1; let f = fn() { a = 5; 3 }; a < f()", true},
+		{"let a = 1; let f = fn() { a = 5; 3 }; a >= f()", false},
+		{`"a" == "a"`, true},
+		{`"a" != "a"`, false},
+		{`"a" == "b"`, false},
+		{`"a" != "b"`, true},
+		{`"mon" + "key" == "monkey"`, true},
+		{`"é" == "é"`, true},
+		{`"1" == 1`, false},
 	}
 
 	for _, tt := range tests {
@@ -58,6 +151,74 @@ func TestEvalBooleanExpression(t *testing.T) {
 	}
 }
 
+func TestLogicalExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"true && true", true},
+		{"true && false", false},
+		{"false && true", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 < 2 && 2 < 3", true},
+		{"1 > 2 || 2 > 3", false},
+		{"1 && 2", 2},
+		{"0 || 5", 0},
+		{"false || 5", 5},
+		{"false && 5", false},
+		{"false && undefined", false},
+		{"true || undefined", true},
+		{"let c = 0; let f = fn() { c += 1; true }; false && f(); true || f(); true && f(); c", 1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		}
+	}
+}
+
+func TestNullExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"null", nil},
+		{"null == null", true},
+		{"1 != null", true},
+		{"if (null) { 1 } else { 2 }", 2},
+		{"null ?? 5", 5},
+		{"0 ?? 5", 0},
+		{"false ?? 5", false},
+		{"null ?? null ?? 3", 3},
+		{`{"a": 1}["b"] ?? 2`, 2},
+		{"1 ?? undefined", 1},
+		{`let h = {"a": {"b": 1}}; h?["a"]?["b"]`, 1},
+		{`let h = null; h?["a"]`, nil},
+		{`let h = {"a": null}; h["a"]?.["b"] ?? 7`, 7},
+		{"let f = null; f?.(1)", nil},
+		{"let f = fn(x) { x * 2 }; f?.(21)", 42},
+		{"let c = 0; let f = null; f?.(c += 1); null?[c += 1]; c", 0},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
 func TestBangOperator(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -111,6 +272,91 @@ func TestIfElseExpressions(t *testing.T) {
 	}
 }
 
+func TestElseIfExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"if (false) { 1 } else if (true) { 2 } else { 3 }", 2},
+		{"if (false) { 1 } else if (false) { 2 } else { 3 }", 3},
+		{"if (false) { 1 } else if (false) { 2 }", nil},
+		{"let f = fn(n) { if (n < 0) { -1 } else if (n == 0) { 0 } else { 1 } }; f(-5) + f(0) * 10 + f(7) * 100", 99},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	describe := `let describe = fn(v) {
+		match (v) {
+			1 => "one",
+			"a" => "letter a",
+			-2.5 => "negative float",
+			true => "true",
+			null => "null",
+			[] => "empty array",
+			[x, 0] => "pair ending in zero after ${x}",
+			[[a, b], c] => a + b + c,
+			{"name": name, "tags": [first, _]} => "${name} tagged ${first}",
+			{} => "a hash",
+			_ => "something else",
+		}
+	};`
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{describe + `describe(1)`, "one"},
+		{describe + `describe("a")`, "letter a"},
+		{describe + `describe(-2.5)`, "negative float"},
+		{describe + `describe(true)`, "true"},
+		{describe + `describe(null)`, "null"},
+		{describe + `describe([])`, "empty array"},
+		{describe + `describe([7, 0])`, "pair ending in zero after 7"},
+		{describe + `describe([[1, 2], 3])`, 6},
+		{describe + `describe({"name": "Ann", "tags": ["x", "y"], "age": 3})`, "Ann tagged x"},
+		{describe + `describe({"name": "Ann", "tags": ["x"]})`, "a hash"},
+		{describe + `describe(1.0)`, "something else"},
+		{describe + `describe("b")`, "something else"},
+		{describe + `describe([1, 2, 3])`, "something else"},
+		{"match (2) { 1 => 10, 2 => 20, 2 => 30 }", 20},
+		{"match (3) { 1 => 10 }", nil},
+		{"match (5) { n => n * 2 }", 10},
+		{"let x = 1; match ([2, 3]) { [x, 4] => x, _ => x }", 1},
+		{"match (1) { 1 => { let y = 4; y * 2 } _ => 0 }", 8},
+		{"let f = fn() { match (1) { 1 => { return 5; } }; 10 }; f()", 5},
+		{"match (match (1) { 1 => [2], _ => [] }) { [n] => n, _ => 0 }", 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("String has wrong value. expected=%q, got=%q", expected, str.Value)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
 func TestReturnStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -192,9 +438,51 @@ func TestErrorHandling(t *testing.T) {
 			}
 			`,
 			"unknown operator: BOOLEAN + BOOLEAN",
+		}, {
+			"+true",
+			"unknown operator: +BOOLEAN",
+		}, {
+			`"a" <= "b"`,
+			"unknown operator: STRING <= STRING",
 		}, {
 			"foobar",
 			"identifier not found: foobar",
+		}, {
+			"match ([1]) { [n] => { let m = n; m } }; n",
+			"identifier not found: n",
+		}, {
+			"if (false) { let y = 1 }; puts(y + 1)",
+			"identifier not found: y",
+		}, {
+			"fn() { if (false) { let y = 1 }; y + 1 }()",
+			"identifier not found: y",
+		}, {
+			"fn() { if (false) { let y = 1 }; fn() { y }() }()",
+			"identifier not found: y",
+		}, {
+			"let f = fn() { if (false) { let y = 1 }; fn() { y } }; f()()",
+			"identifier not found: y",
+		}, {
+			"for (x in []) {}; puts(x)",
+			"identifier not found: x",
+		}, {
+			"fn() { for (x in []) {}; x }()",
+			"identifier not found: x",
+		}, {
+			"fn() { for (k, v in {}) {}; fn() { k }() }()",
+			"identifier not found: k",
+		}, {
+			"let f = fn(a = b, b = 2) { a }; f()",
+			"identifier not found: b",
+		}, {
+			"let b = 1; let f = fn(a = b, b = 2) { a }; f()",
+			"identifier not found: b",
+		}, {
+			"let f = fn(a = a) { a }; f()",
+			"identifier not found: a",
+		}, {
+			"let f = fn(a = rest, ...rest) { a }; f()",
+			"identifier not found: rest",
 		}, {
 			`"Hello" - "world"`,
 			"unknown operator: STRING - STRING",
@@ -219,6 +507,183 @@ func TestErrorHandling(t *testing.T) {
 	}
 }
 
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedPosition string
+	}{
+		{"5 + true;", "1:3"},
+		{"let a = 1;\n  foobar", "2:3"},
+		{"let f = fn(x) {\n  x * true\n};\nf(1)", "2:5"},
+		{`len(1)`, "1:4"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errorObject, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if errorObject.Position.String() != tt.expectedPosition {
+			t.Errorf("wrong error position. Expected=%q, got=%q", tt.expectedPosition, errorObject.Position)
+		}
+	}
+}
+
+func TestErrorStackTraces(t *testing.T) {
+	input := `let inner = fn(x) {
+  x + true
+};
+let outer = fn(y) { inner(y) };
+fn() { outer(1) }();`
+
+	evaluated := testEval(input)
+
+	errorObject, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	expected := []string{
+		"in inner, called at 4:26",
+		"in outer, called at 5:13",
+		"in <anonymous>, called at 5:18",
+	}
+
+	if len(errorObject.StackTrace) != len(expected) {
+		t.Fatalf("wrong stack trace length. Expected=%d, got=%d (%v)", len(expected), len(errorObject.StackTrace), errorObject.StackTrace)
+	}
+
+	for i, frame := range expected {
+		if errorObject.StackTrace[i].String() != frame {
+			t.Errorf("wrong stack frame %d. Expected=%q, got=%q", i, frame, errorObject.StackTrace[i].String())
+		}
+	}
+}
+
+func TestWhileStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"while (false) { 1 }", nil},
+		{"let f = fn(n) { while (n > 0) { return n; } }; f(3)", 3},
+		{"let f = fn(n) { while (n > 0) { return n; } }; f(0)", nil},
+		{"let f = fn() { while (true) { break; } 5 }; f()", 5},
+		{`
+		let sum = fn(arr) {
+			let go = fn(i, acc) {
+				while (i < len(arr)) {
+					return go(i + 1, acc + arr[i]);
+				}
+				acc
+			};
+			go(0, 0)
+		};
+		sum([1, 2, 3, 4])`, 10},
+		{"let f = fn() { while (true) { if (true) { break; } 99 } 7 }; f()", 7},
+		{"let f = fn(n) { while (n > 0) { if (n > 1) { continue; } return 1; } 2 }; f(0)", 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else if evaluated != nil && evaluated != NULL {
+			t.Errorf("%q: expected no value. got=%T (%+v)", tt.input, evaluated, evaluated)
+		}
+	}
+}
+
+func TestBreakAndContinueInExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; let seen = []; while (i < 3) { i += 1; seen = push(seen, if (i == 2) { break; } else { i }); }; seen", []int{1}},
+		{"let i = 0; let seen = []; while (i < 3) { i += 1; seen = push(seen, if (i == 2) { continue; } else { i }); }; seen", []int{1, 3}},
+		{"let i = 0; while (true) { i += 1; let r = if (i == 3) { break; }; }; i", 3},
+		{"let n = 0; for (x in [1, 2, 3]) { n += if (x == 2) { continue; } else { x }; }; n", 4},
+		{"let n = 0; for (x in [1, 2, 3]) { n = n + -(if (x == 3) { break; } else { x }); }; n", -3},
+		{"let f = fn() { [1, if (true) { return 2; }, 3] }; f()", 2},
+		{"let f = fn() { while (false) {} }; f()", nil},
+		{"let f = fn() { for (x in []) {} }; f()", nil},
+		{"let f = fn() { let x = 1; }; f()", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case []int:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("%q: object is not Array. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if len(array.Elements) != len(expected) {
+				t.Errorf("%q: wrong number of elements. want=%d, got=%d", tt.input, len(expected), len(array.Elements))
+				continue
+			}
+			for i, element := range expected {
+				testIntegerObject(t, array.Elements[i], int64(element))
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestForStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"for (x in []) { 1 }", nil},
+		{"for (x in [1, 2, 3]) { }; x", 3},
+		{"for (i, x in [4, 5, 6]) { }; i", 2},
+		{"let f = fn(arr) { for (x in arr) { if (x > 2) { return x; } } 0 }; f([1, 2, 3, 4])", 3},
+		{"let f = fn() { for (x in [1, 2, 3]) { if (x < 3) { continue; } return x * 10; } }; f()", 30},
+		{"for (x in [1, 2]) { for (y in [10, 20]) { break; } }; x + y", 12},
+		{`for (k in {"b": 2, "a": 1}) { break; }; k`, "a"},
+		{`for (k, v in {"b": 2, "a": 1, "c": 3}) { if (v == 2) { break; } }; k`, "b"},
+		{`for (k, v in {3: 1, 10: 2, -1: 3}) { break; }; k`, -1},
+		{`for (i, c in "héllo") { }; c`, "o"},
+		{`for (i, c in "héllo") { }; i`, 4},
+		{`for (x in 1) { }`, "cannot iterate over INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			switch result := evaluated.(type) {
+			case *object.String:
+				if result.Value != expected {
+					t.Errorf("%q: String has wrong value. got=%q, want=%q", tt.input, result.Value, expected)
+				}
+			case *object.Error:
+				if result.Message != expected {
+					t.Errorf("%q: wrong error message. got=%q, want=%q", tt.input, result.Message, expected)
+				}
+			default:
+				t.Errorf("%q: object is not String or Error. got=%T (%+v)", tt.input, evaluated, evaluated)
+			}
+		default:
+			if evaluated != nil && evaluated != NULL {
+				t.Errorf("%q: expected no value. got=%T (%+v)", tt.input, evaluated, evaluated)
+			}
+		}
+	}
+}
+
 func TestLetStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -235,6 +700,145 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestDestructuringLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let [a, b] = [1, 2]; a * 10 + b", 12},
+		{"let [a, b] = [1]; b ?? 5", 5},
+		{"let [a, b = 2] = [1]; a + b", 3},
+		{"let [a, b = 2] = [1, null]; b", 2},
+		{"let [a, b = a * 3] = [4]; b", 12},
+		{"let [a, _, c] = [1, 2, 3]; a + c", 4},
+		{"let [first, ...rest] = [1, 2, 3]; first + len(rest) * 10 + rest[1]", 24},
+		{"let [...all] = []; len(all)", 0},
+		{"let [a, ...rest] = [1]; len(rest)", 0},
+		{`let {"x": x, "y": y} = {"x": 3, "y": 4}; x * y`, 12},
+		{`let {name, age = 3} = {"name": 1}; name + age`, 4},
+		{`let {a, ...others} = {"a": 1, "b": 2, "c": 3}; (others["a"] ?? 20) + others["c"]`, 23},
+		{`let {1: one, true: yes} = {1: 5, true: 6}; one + yes`, 11},
+		{`let [[a, b], {"c": [c]}] = [[1, 2], {"c": [3]}]; a + b + c`, 6},
+		{"let a = 1; let b = 2; let [a, b] = [b, a]; a * 10 + b", 21},
+		{"let f = fn(pair) { let [a, b] = pair; a - b }; f([5, 3])", 2},
+		{"let f = fn() { let [a, ...r] = [1, 2]; fn() { a + r[0] } }; f()()", 3},
+		{"let [x] = [7]; match ([x, 8]) { [first, ...rest] => first + rest[0], _ => 0 }", 15},
+		{`match ({"a": 1, "b": 2}) { {"a": a, ...rest} => a + rest["b"], _ => 0 }`, 3},
+		{"match ([1]) { [a, b, ...rest] => 1, _ => 2 }", 2},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestConstStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"const x = 5; x", 5},
+		{`const [a, {b}] = [1, {"b": 2}]; a + b`, 3},
+		{"const x = 1; let f = fn() { let x = 2; x = 3; x }; f() + x", 4},
+		{"const x = 1; let f = fn(x) { x += 1; x }; f(5)", 6},
+		{"const f = fn(n) { if (n == 0) { 0 } else { n + f(n - 1) } }; f(3)", 6},
+		{"let a = freeze([1, [2]]); a[0] + a[1][0]", 3},
+		{"let a = freeze([1]); let b = push(a, 2); b[0] = 5; b[0] + a[0]", 6},
+		{"let [first, ...rest] = freeze([1, 2]); rest[0] = 7; rest[0]", 7},
+		{"const a = [1]; a[0] = 4; a[0]", 4},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5; a = 10; a;", 10},
+		{"let a = 5; a = a + 1;", 6},
+		{"let a = 5; a += 2; a -= 10; a", -3},
+		{"let a = 1; let b = 2; a = b = 7; a + b", 14},
+		{"let a = 1; let f = fn() { a = 5; }; f(); a", 5},
+		{"let f = fn(x) { x += 1; x }; f(1)", 2},
+		{"let a = 1; let f = fn() { let a = 2; a = 3; }; f(); a", 1},
+		{"let i = 0; let sum = 0; while (i < 5) { i += 1; sum += i; } sum", 15},
+		{"let sum = 0; for (x in [1, 2, 3]) { if (x == 2) { continue; } sum += x; } sum", 4},
+		{"let counter = fn() { let c = 0; fn() { c += 1 } }(); counter(); counter(); counter()", 3},
+		{"let make = fn() { let c = 0; let inc = fn() { c += 1 }; inc(); inc(); c }; make()", 2},
+		{"let pair = fn() { let c = 0; [fn() { c += 1 }, fn() { c }] }(); pair[0](); pair[0](); pair[1]()", 2},
+		{"let f = fn() { let c = 0; let g = fn() { let h = fn() { c += 5 }; h() }; g(); c }; f()", 5},
+		{"let f = fn() { let c = 1; let g = fn() { c }; c = 7; g() }; f()", 7},
+		{"let f = fn(x) { let g = fn() { x = x * 2 }; g(); x }; f(4)", 8},
+		{"let make = fn() { let c = 0; fn() { c += 1 } }; let a = make(); let b = make(); a(); a(); b()", 1},
+		{"let a = [1, 2, 3]; a[1] = 5; a[0] + a[1] + a[2]", 9},
+		{"let a = [1, 2, 3]; a[2] += 10", 13},
+		{`let h = {"a": 1}; h["b"] = 2; h["a"] -= 5; h["a"] + h["b"]`, -2},
+		{"let a = [[1]]; a[0][0] = 4; a[0][0]", 4},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestAssignErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"x = 1", "assignment to undeclared variable x"},
+		{"len += 1", "assignment to undeclared variable len"},
+		{"let a = [1]; a[1] = 2", "index out of range: 1"},
+		{"let a = 1; a += true", "type mismatch: INTEGER + BOOLEAN"},
+		{"let a = 1; a[0] = 1", "Index assignment is not defined on type: INTEGER"},
+		{"let h = {}; h[fn() {}] = 1", "Unusable as hash key: FUNCTION"},
+		{"let [a] = 1", "cannot destructure INTEGER as an array"},
+		{`let {a} = [1]`, "cannot destructure ARRAY as a hash"},
+		{"let f = fn(x) { x }; f(...1)", "cannot spread INTEGER"},
+		{"let a = freeze([1]); a[0] = 2", "cannot modify frozen ARRAY"},
+		{"let a = freeze([[1]]); a[0][0] += 2", "cannot modify frozen ARRAY"},
+		{`let h = freeze({"k": {"j": 1}}); h["k"]["j"] = 2`, "cannot modify frozen HASH"},
+		{`let h = freeze({}); h["k"] = 1`, "cannot modify frozen HASH"},
+		{"freeze(1)", "Invalid argument passed to `freeze()`. Expected=ARRAY or HASH, got=INTEGER"},
+		{"const x = 1; x = 2", "cannot assign to constant x"},
+		{"const x = 1; let f = fn() { x += 1 }; f()", "cannot assign to constant x"},
+		{"const x = 1; let x = 2", "cannot redeclare constant x"},
+		{"const x = 1; let [y, x] = [1, 2]", "cannot redeclare constant x"},
+		{"const i = 0; for (i in [1, 2, 3]) {}", "cannot redeclare constant i"},
+		{"const i = 0; for (i in []) {}", "cannot redeclare constant i"},
+		{"const k = 0; for (k, v in {1: 2}) {}", "cannot redeclare constant k"},
+		{"let f = fn(x = 1 + true) { x }; f()", "type mismatch: INTEGER + BOOLEAN"},
+		{"fn() { 1 }(1)", "wrong number of arguments: want=0, got=1"},
+		{"fn(a, b) { a + b }(1)", "wrong number of arguments: want=2, got=1"},
+		{"fn(a, b = 1) { a + b }(1, 2, 3)", "wrong number of arguments: want=1 to 2, got=3"},
+		{"fn(a, ...b) { a }()", "wrong number of arguments: want=at least 1, got=0"},
+		{"fn(a, b = 1) { a + b }(b: 2)", "missing argument for parameter a"},
+		{"fn(a, b) { a + b }(a: 2)", "missing argument for parameter b"},
+		{"fn(a) { a }(1, a: 2)", "parameter a got more than one argument"},
+		{"fn(a, ...b) { a }(b: 2)", "unknown parameter b"},
+		{`len(x: "one")`, "builtin functions don't take named arguments"},
+		{`let [{a}] = ["a"]`, "cannot destructure STRING as a hash"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errorObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if errorObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. Expected=%q, got=%q", tt.expectedMessage, errorObj.Message)
+		}
+	}
+}
+
 func TestFunctionObjet(t *testing.T) {
 	input := "fn(x) { x + 2; }"
 
@@ -277,6 +881,48 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
+func TestFunctionDefaultsRestAndSpread(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let add = fn(x, y = 2) { x + y }; add(1)", 3},
+		{"let add = fn(x, y = 2) { x + y }; add(1, 5)", 6},
+		{"let add = fn(x, y = 2) { x + y }; add(1, null)", 3},
+		{"let f = fn(x, y = x * 10) { y }; f(3)", 30},
+		{"let f = fn(a = 1, b = a + 1) { a + b }; f()", 3},
+		{"let b = 1; let f = fn(a, b = 2) { a + b }; f(5)", 7},
+		{"let base = 7; let f = fn(x = base) { x }; f()", 7},
+		{"let f = fn() { let base = 8; fn(x = base) { x } }; f()()", 8},
+		{"let f = fn(a = 1, b = 2, c = 3) { a * 100 + b * 10 + c }; f(4, null, 6)", 426},
+		{"let f = fn(n, acc = 0) { if (n == 0) { acc } else { f(n - 1, acc + n) } }; f(4)", 10},
+		{"let f = fn(...rest) { len(rest) }; f()", 0},
+		{"let f = fn(...rest) { len(rest) }; f(1, 2, 3)", 3},
+		{"let f = fn(x, ...rest) { x + rest[1] }; f(1, 2, 3)", 4},
+		{"let f = fn(x, y = 5, ...rest) { x + y + len(rest) }; f(1)", 6},
+		{"let f = fn(x, y = 5, ...rest) { x + y + len(rest) }; f(1, 2, 3, 4)", 5},
+		{"let f = fn(x, ...rest) { fn() { x + len(rest) } }; f(1, 2)()", 2},
+		{"let add = fn(x, y) { x + y }; add(...[1, 2])", 3},
+		{"let add = fn(x, y) { x + y }; add(1, ...[2])", 3},
+		{"let f = fn(a, b, c, d) { a * 1000 + b * 100 + c * 10 + d }; f(...[1], 2, ...[], ...[3, 4])", 1234},
+		{"let sum = fn(...xs) { let t = 0; for (x in xs) { t += x } t }; sum(...[1, 2], 3, ...[4])", 10},
+		{"let xs = [1]; let f = fn(...a) { len(a) }; f(...xs, ...xs); len(xs)", 1},
+		{`len(...["four"])`, 4},
+		{"let f = fn(x) { x }; f?.(...[5])", 5},
+		{"let f = fn(x, y = 2, z = 3) { x * 100 + y * 10 + z }; f(1, z: 5)", 125},
+		{"let f = fn(x, y = 2, z = 3) { x * 100 + y * 10 + z }; f(z: 5, y: 4, x: 1)", 145},
+		{"let f = fn(x, y = 2, z = 3) { x * 100 + y * 10 + z }; f(y: null, x: 1)", 123},
+		{"let f = fn(a, b = a * 2) { b }; f(a: 4)", 8},
+		{"let f = fn(a, ...rest) { a + len(rest) }; f(a: 4)", 4},
+		{"let f = fn(x) { fn(y = 1, z = 2) { x + y * z } }; f(1)(z: 10)", 11},
+		{"let f = fn(x) { x }; f?.(x: 5)", 5},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
 func TestClosures(t *testing.T) {
 	input := `
 		let newAdder = fn(x) {
@@ -335,6 +981,33 @@ func TestStringConcatenation(t *testing.T) {
 	}
 }
 
+func TestInterpolatedStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let name = "monkey"; "hello ${name}!"`, "hello monkey!"},
+		{`"${1} + ${2.5} = ${1 + 2.5}"`, "1 + 2.5 = 3.5"},
+		{`"${[1, true]} ${{"a": 1}} ${if (false) { 1 }}"`, "[1, true] {a: 1} null"},
+		{`"${"outer ${"inner"}"}"`, "outer inner"},
+		{`let f = fn(x) { "<${x}>" }; f(f(1))`, "<<1>>"},
+		{`"\${not} $interpolated"`, "${not} $interpolated"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("%q: object is not String. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if str.Value != tt.expected {
+			t.Errorf("%q: String has wrong value. expected=%q, got=%q", tt.input, tt.expected, str.Value)
+		}
+	}
+}
+
 func TestBuiltinFunctions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -344,6 +1017,8 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("four")`, 4},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
+		{`len("ñ")`, 1},
+		{`len("héllo")`, 5},
 		{`len(1)`, "Invalid argument passed to `len()`. Got=INTEGER"},
 		{`len("one", "two")`, "Invalid amount of arguments. Expected=1, got=2"},
 		{`len([1, 2, 3])`, 3},
@@ -359,6 +1034,23 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`push([], 1)`, []int{1}},
 		{`push(1, 1)`, "Invalid argument passed to `push()`. Expected=ARRAY, got=INTEGER"},
 		{`puts("Hello", "world")`, nil},
+		{`int(2.9)`, 2},
+		{`int(-2.9)`, -2},
+		{`int(7)`, 7},
+		{`int("42")`, 42},
+		{`int("4.2")`, "could not parse \"4.2\" as integer"},
+		{`int(1e19)`, "1e+19 is out of the integer range"},
+		{`int(true)`, "Invalid argument passed to `int()`. Got=BOOLEAN"},
+		{`floor(2.5)`, 2},
+		{`floor(-2.5)`, -3},
+		{`floor(3)`, 3},
+		{`round(2.5)`, 3},
+		{`round(2.49)`, 2},
+		{`round(-2.5)`, -3},
+		{`float(1)`, 1.0},
+		{`float("0.25")`, 0.25},
+		{`float(1.5)`, 1.5},
+		{`float([])`, "Invalid argument passed to `float()`. Got=ARRAY"},
 	}
 
 	for _, tt := range tests {
@@ -367,6 +1059,8 @@ func TestBuiltinFunctions(t *testing.T) {
 		switch expected := tt.expected.(type) {
 		case int:
 			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
 		case string:
 			errObj, ok := evaluated.(*object.Error)
 			if !ok {
@@ -396,6 +1090,25 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
+func TestOutputIsPerEnvironment(t *testing.T) {
+	var first, second bytes.Buffer
+
+	firstEnvironment := object.NewEnvironment()
+	firstEnvironment.SetOutput(&first)
+	secondEnvironment := object.NewEnvironment()
+	secondEnvironment.SetOutput(&second)
+
+	Eval(parser.New(lexer.New(`let f = fn() { puts("one") }; f()`)).ParseProgram(), firstEnvironment)
+	Eval(parser.New(lexer.New(`puts("two", 2)`)).ParseProgram(), secondEnvironment)
+
+	if first.String() != "one\n" {
+		t.Errorf("wrong output of the first environment. expected=%q, got=%q", "one\n", first.String())
+	}
+	if second.String() != "two\n2\n" {
+		t.Errorf("wrong output of the second environment. expected=%q, got=%q", "two\n2\n", second.String())
+	}
+}
+
 func TestArrayLiterals(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 
@@ -552,6 +1265,21 @@ func testEval(input string) object.Object {
 	return Eval(program, environment)
 }
 
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not a Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("object has wrong value. Expected=%g, got=%g.", expected, result.Value)
+		return false
+	}
+
+	return true
+}
+
 func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	result, ok := obj.(*object.Integer)
 	if !ok {
@@ -0,0 +1,16 @@
+package lexer
+
+import (
+	"fmt"
+	"monkey-lang/token"
+)
+
+// Error describes a malformed token, the lexer returns an ILLEGAL token for it
+type Error struct {
+	Position token.Position // where the malformed token starts
+	Message  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Position, e.Message)
+}
@@ -1,34 +1,92 @@
 package lexer
 
-import "monkey-lang/token"
+import (
+	"fmt"
+	"monkey-lang/token"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
 
 type Lexer struct {
 	input        string
 	position     int
 	readPosition int
-	currentChar  byte
+	currentChar  rune
+	charWidth    int // the number of bytes currentChar takes up in input
+
+	filename string
+	line     int // the line of currentChar, starting at 1
+	column   int // the column of currentChar, starting at 1 and counted in characters rather than bytes
+
+	// The brace depth within each ${...} of an interpolated string we are in,
+	// innermost last. The `}` closing one at depth 0 resumes the string
+	interpolations []int
+
+	comments []token.Token
+	errors   []*Error
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewWithFilename(input, "")
+}
+
+// NewWithFilename creates a lexer whose token positions refer to the given file
+func NewWithFilename(input string, filename string) *Lexer {
+	l := &Lexer{input: input, filename: filename, line: 1, column: 1}
 	l.readChar()
 	return l
 }
 
 func (l *Lexer) readChar() {
+	if l.charWidth > 0 {
+		l.column++
+	}
+	if l.currentChar == '\n' {
+		l.line++
+		l.column = 1
+	}
+
 	if l.readPosition >= len(l.input) {
-		l.currentChar = 0
+		l.currentChar, l.charWidth = 0, 0
 	} else {
-		l.currentChar = l.input[l.readPosition]
+		l.currentChar, l.charWidth = utf8.DecodeRuneInString(l.input[l.readPosition:])
 	}
 	l.position = l.readPosition
-	l.readPosition += 1
+	l.readPosition += l.charWidth
+}
+
+// Comments returns the comments skipped so far as COMMENT tokens, in source
+// order, so that tools like formatters can put them back
+func (l *Lexer) Comments() []token.Token {
+	return l.comments
+}
+
+// Errors returns the problems found in the malformed tokens read so far
+func (l *Lexer) Errors() []*Error {
+	return l.errors
+}
+
+// ErrorAt returns the error recorded for the ILLEGAL token at position, if any
+func (l *Lexer) ErrorAt(position token.Position) (*Error, bool) {
+	for _, err := range l.errors {
+		if err.Position == position {
+			return err, true
+		}
+	}
+
+	return nil, false
 }
 
 func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 
-	l.skipWhitespace()
+	if err := l.skipWhitespace(); err != nil {
+		return l.illegalToken(err.Position, err.Message)
+	}
+
+	position := l.currentPosition()
 
 	switch l.currentChar {
 	case '=':
@@ -37,13 +95,32 @@ func (l *Lexer) NextToken() token.Token {
 			l.readChar()
 			literal := string(currentChar) + string(l.currentChar)
 			tok = token.Token{Type: token.EQUAL, Literal: literal}
+		} else if l.peekChar() == '>' {
+			currentChar := l.currentChar
+			l.readChar()
+			literal := string(currentChar) + string(l.currentChar)
+			tok = token.Token{Type: token.ARROW, Literal: literal}
 		} else {
 			tok = newToken(token.ASSIGNMENT, l.currentChar)
 		}
 	case '+':
-		tok = newToken(token.PLUS, l.currentChar)
+		if l.peekChar() == '=' {
+			currentChar := l.currentChar
+			l.readChar()
+			literal := string(currentChar) + string(l.currentChar)
+			tok = token.Token{Type: token.PLUSASSIGNMENT, Literal: literal}
+		} else {
+			tok = newToken(token.PLUS, l.currentChar)
+		}
 	case '-':
-		tok = newToken(token.MINUS, l.currentChar)
+		if l.peekChar() == '=' {
+			currentChar := l.currentChar
+			l.readChar()
+			literal := string(currentChar) + string(l.currentChar)
+			tok = token.Token{Type: token.MINUSASSIGNMENT, Literal: literal}
+		} else {
+			tok = newToken(token.MINUS, l.currentChar)
+		}
 	case '!':
 		if l.peekChar() == '=' {
 			currentChar := l.currentChar
@@ -57,10 +134,66 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.ASTERISK, l.currentChar)
 	case '/':
 		tok = newToken(token.SLASH, l.currentChar)
+	case '&':
+		if l.peekChar() == '&' {
+			currentChar := l.currentChar
+			l.readChar()
+			literal := string(currentChar) + string(l.currentChar)
+			tok = token.Token{Type: token.AND, Literal: literal}
+		} else {
+			tok = newToken(token.ILLEGAL, l.currentChar)
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			currentChar := l.currentChar
+			l.readChar()
+			literal := string(currentChar) + string(l.currentChar)
+			tok = token.Token{Type: token.OR, Literal: literal}
+		} else {
+			tok = newToken(token.ILLEGAL, l.currentChar)
+		}
+	case '.':
+		if l.peekChar() == '.' && l.peekCharAt(1) == '.' {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tok = newToken(token.ILLEGAL, l.currentChar)
+		}
+	case '?':
+		switch l.peekChar() {
+		case '?':
+			l.readChar()
+			tok = token.Token{Type: token.NULLCOALESCE, Literal: "??"}
+		case '.':
+			l.readChar()
+			tok = token.Token{Type: token.QUESTIONDOT, Literal: "?."}
+		case '[':
+			l.readChar()
+			tok = token.Token{Type: token.QUESTIONBRACKET, Literal: "?["}
+		default:
+			tok = newToken(token.ILLEGAL, l.currentChar)
+		}
+	case '%':
+		tok = newToken(token.PERCENT, l.currentChar)
 	case '<':
-		tok = newToken(token.LESSTHAN, l.currentChar)
+		if l.peekChar() == '=' {
+			currentChar := l.currentChar
+			l.readChar()
+			literal := string(currentChar) + string(l.currentChar)
+			tok = token.Token{Type: token.LESSTHANOREQUAL, Literal: literal}
+		} else {
+			tok = newToken(token.LESSTHAN, l.currentChar)
+		}
 	case '>':
-		tok = newToken(token.GREATERTHAN, l.currentChar)
+		if l.peekChar() == '=' {
+			currentChar := l.currentChar
+			l.readChar()
+			literal := string(currentChar) + string(l.currentChar)
+			tok = token.Token{Type: token.GREATERTHANOREQUAL, Literal: literal}
+		} else {
+			tok = newToken(token.GREATERTHAN, l.currentChar)
+		}
 	case ';':
 		tok = newToken(token.SEMICOLON, l.currentChar)
 	case '(':
@@ -70,16 +203,28 @@ func (l *Lexer) NextToken() token.Token {
 	case ',':
 		tok = newToken(token.COMMA, l.currentChar)
 	case '{':
+		if len(l.interpolations) > 0 {
+			l.interpolations[len(l.interpolations)-1]++
+		}
 		tok = newToken(token.OPENBRACE, l.currentChar)
 	case '}':
-		tok = newToken(token.CLOSEBRACE, l.currentChar)
+		if depth := len(l.interpolations) - 1; depth >= 0 && l.interpolations[depth] == 0 {
+			l.interpolations = l.interpolations[:depth]
+			tok = l.readString(position, token.STRINGMIDDLE, token.STRINGTAIL)
+		} else {
+			if depth >= 0 {
+				l.interpolations[depth]--
+			}
+			tok = newToken(token.CLOSEBRACE, l.currentChar)
+		}
 	case '[':
 		tok = newToken(token.OPENBRACKET, l.currentChar)
 	case ']':
 		tok = newToken(token.CLOSEBRACKET, l.currentChar)
 	case '"':
-		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		tok = l.readString(position, token.STRINGHEAD, token.STRING)
+	case '`':
+		tok = l.readRawString(position)
 	case ':':
 		tok = newToken(token.COLON, l.currentChar)
 	case 0:
@@ -89,37 +234,98 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.currentChar) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdentifier(tok.Literal)
+			tok.Position = position
 			return tok
 		} else if isDigit(l.currentChar) {
-			tok.Type = token.INTEGER
-			tok.Literal = l.readNumber()
+			tok.Type, tok.Literal = l.readNumber()
+			tok.Position = position
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.currentChar)
 		}
 	}
 
+	tok.Position = position
+
 	l.readChar()
 	return tok
 }
 
-func (l *Lexer) skipWhitespace() {
-	for l.currentChar == ' ' || l.currentChar == '\t' ||
-		l.currentChar == '\n' || l.currentChar == '\r' {
-		l.readChar()
+func (l *Lexer) currentPosition() token.Position {
+	return token.Position{
+		Filename: l.filename,
+		Line:     l.line,
+		Column:   l.column,
+		Offset:   l.position,
 	}
 }
 
-func newToken(tokenType token.TokenType, character byte) token.Token {
-	return token.Token{Type: tokenType, Literal: string(character)}
+// skipWhitespace skips whitespace and comments, which are `# ...` or
+// `// ...` up to the end of the line or `/* ... */`, where block comments nest.
+// It returns an error for a block comment that is never closed
+func (l *Lexer) skipWhitespace() *Error {
+	for {
+		switch {
+		case l.currentChar == ' ' || l.currentChar == '\t' ||
+			l.currentChar == '\n' || l.currentChar == '\r':
+			l.readChar()
+		case l.currentChar == '#' || l.currentChar == '/' && l.peekChar() == '/':
+			l.skipLineComment()
+		case l.currentChar == '/' && l.peekChar() == '*':
+			if err := l.skipBlockComment(); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
 }
 
-func (l *Lexer) peekChar() byte {
-	if l.readPosition >= len(l.input) {
-		return 0
+func (l *Lexer) skipLineComment() {
+	position := l.currentPosition()
+	for l.currentChar != '\n' && l.currentChar != 0 {
+		l.readChar()
 	}
 
-	return l.input[l.readPosition]
+	l.addComment(position)
+}
+
+func (l *Lexer) skipBlockComment() *Error {
+	position := l.currentPosition()
+	depth := 0
+
+	for {
+		switch {
+		case l.currentChar == 0:
+			return &Error{Position: position, Message: "unterminated block comment"}
+		case l.currentChar == '/' && l.peekChar() == '*':
+			depth++
+			l.readChar()
+		case l.currentChar == '*' && l.peekChar() == '/':
+			depth--
+			l.readChar()
+		}
+		l.readChar()
+
+		if depth == 0 {
+			l.addComment(position)
+			return nil
+		}
+	}
+}
+
+// addComment records the comment that started at position and ended right before the current character
+func (l *Lexer) addComment(position token.Position) {
+	comment := token.Token{Type: token.COMMENT, Literal: l.input[position.Offset:l.position], Position: position}
+	l.comments = append(l.comments, comment)
+}
+
+func newToken(tokenType token.TokenType, character rune) token.Token {
+	return token.Token{Type: tokenType, Literal: string(character)}
+}
+
+func (l *Lexer) peekChar() rune {
+	return l.peekCharAt(0)
 }
 
 func (l *Lexer) readIdentifier() string {
@@ -131,32 +337,196 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readNumber() string {
+// peekCharAt returns the character offset characters after the next one, or 0 past the end of input
+func (l *Lexer) peekCharAt(offset int) rune {
+	position := l.readPosition
+	for ; offset > 0 && position < len(l.input); offset-- {
+		_, width := utf8.DecodeRuneInString(l.input[position:])
+		position += width
+	}
+
+	if position >= len(l.input) {
+		return 0
+	}
+
+	character, _ := utf8.DecodeRuneInString(l.input[position:])
+	return character
+}
+
+// readNumber reads an integer, or a float if the digits are followed by a
+// fraction like `1.5` or an exponent like `1e-3`
+func (l *Lexer) readNumber() (token.TokenType, string) {
 	position := l.position
+	tokenType := token.TokenType(token.INTEGER)
 
 	for isDigit(l.currentChar) {
 		l.readChar()
 	}
 
-	return l.input[position:l.position]
+	if l.currentChar == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+		l.readChar()
+
+		for isDigit(l.currentChar) {
+			l.readChar()
+		}
+	}
+
+	if l.currentChar == 'e' || l.currentChar == 'E' {
+		sign := l.peekChar() == '+' || l.peekChar() == '-'
+		if isDigit(l.peekChar()) || sign && isDigit(l.peekCharAt(1)) {
+			tokenType = token.FLOAT
+			l.readChar()
+			if sign {
+				l.readChar()
+			}
+
+			for isDigit(l.currentChar) {
+				l.readChar()
+			}
+		}
+	}
+
+	return tokenType, l.input[position:l.position]
 }
 
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+func isLetter(ch rune) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' ||
+		ch >= utf8.RuneSelf && unicode.IsLetter(ch)
 }
 
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
-func (l *Lexer) readString() string {
-	position := l.position + 1
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
+// readString reads a double quoted string, replacing escape sequences with
+// the characters they stand for. The string ends either at the closing quote,
+// giving an endType token, or at the start of a ${...} interpolation, giving an
+// interpolatedType token. It returns an ILLEGAL token holding the raw source if
+// the string is malformed
+func (l *Lexer) readString(position token.Position, interpolatedType, endType token.TokenType) token.Token {
+	var value strings.Builder
+	var message string
+
+	for {
+		l.readChar()
+
+		if l.currentChar == '"' {
+			break
+		}
+
+		if l.currentChar == 0 {
+			return l.illegalToken(position, "unterminated string literal")
+		}
+
+		if l.currentChar == '$' && l.peekChar() == '{' {
+			l.readChar()
+			l.interpolations = append(l.interpolations, 0)
+			endType = interpolatedType
+			break
+		}
+
+		if l.currentChar != '\\' {
+			value.WriteRune(l.currentChar)
+			continue
+		}
+
+		l.readChar()
+		switch l.currentChar {
+		case 'n':
+			value.WriteByte('\n')
+		case 't':
+			value.WriteByte('\t')
+		case 'r':
+			value.WriteByte('\r')
+		case '"':
+			value.WriteByte('"')
+		case '$':
+			value.WriteByte('$')
+		case '\\':
+			value.WriteByte('\\')
+		case 'u':
+			character, ok := l.readUnicodeEscape()
+			if !ok && message == "" {
+				message = "invalid unicode escape sequence"
+			}
+			value.WriteRune(character)
+		case 0:
+			return l.illegalToken(position, "unterminated string literal")
+		default:
+			if message == "" {
+				message = fmt.Sprintf("invalid escape sequence \\%c", l.currentChar)
+			}
+			// keep scanning so that an escaped quote doesn't end the string early
+		}
+	}
+
+	if message != "" {
+		return l.illegalToken(position, message)
+	}
+
+	return token.Token{Type: endType, Literal: value.String()}
+}
+
+// readUnicodeEscape reads the `{1F600}` part of a `\u{1F600}` escape sequence,
+// stopping at the closing brace or at the first character that doesn't belong
+func (l *Lexer) readUnicodeEscape() (rune, bool) {
+	if l.peekChar() != '{' {
+		return utf8.RuneError, false
+	}
+	l.readChar()
+
+	start := l.readPosition
+	for isHexDigit(l.peekChar()) {
+		l.readChar()
+	}
+	digits := l.input[start:l.readPosition]
+
+	if l.peekChar() != '}' || len(digits) == 0 || len(digits) > 6 {
+		return utf8.RuneError, false
+	}
+	l.readChar()
+
+	value, _ := strconv.ParseUint(digits, 16, 32)
+	character := rune(value)
+	if !utf8.ValidRune(character) {
+		return utf8.RuneError, false
+	}
+
+	return character, true
+}
+
+// readRawString reads a backtick quoted string, which can span lines and has no escape sequences
+func (l *Lexer) readRawString(position token.Position) token.Token {
+	start := l.position + 1
 	for {
 		l.readChar()
-		if l.currentChar == '"' || l.currentChar == 0 {
+
+		if l.currentChar == '`' {
 			break
 		}
+
+		if l.currentChar == 0 {
+			return l.illegalToken(position, "unterminated raw string literal")
+		}
 	}
 
-	return l.input[position:l.position]
+	return token.Token{Type: token.STRING, Literal: l.input[start:l.position]}
+}
+
+// illegalToken records an error for the malformed token starting at position
+// and returns an ILLEGAL token holding its source
+func (l *Lexer) illegalToken(position token.Position, message string) token.Token {
+	l.errors = append(l.errors, &Error{Position: position, Message: message})
+
+	end := l.position
+	if end < len(l.input) {
+		end += l.charWidth
+	}
+
+	return token.Token{Type: token.ILLEGAL, Literal: l.input[position.Offset:end], Position: position}
 }
@@ -14,7 +14,7 @@ func TestNextToken(t *testing.T) {
 	
 	let result = add(five, ten);
 	
-	!-/*5;
+	!-/ *5;
 	5 < 10 > 5;
 
 	if (5 < 10) {
@@ -138,3 +138,591 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestNextTokenPositions(t *testing.T) {
+	input := `let x = 5;
+  x + "a
+b";`
+
+	tests := []struct {
+		expectedLiteral  string
+		expectedPosition token.Position
+	}{
+		{"let", token.Position{Filename: "test.monkey", Line: 1, Column: 1, Offset: 0}},
+		{"x", token.Position{Filename: "test.monkey", Line: 1, Column: 5, Offset: 4}},
+		{"=", token.Position{Filename: "test.monkey", Line: 1, Column: 7, Offset: 6}},
+		{"5", token.Position{Filename: "test.monkey", Line: 1, Column: 9, Offset: 8}},
+		{";", token.Position{Filename: "test.monkey", Line: 1, Column: 10, Offset: 9}},
+		{"x", token.Position{Filename: "test.monkey", Line: 2, Column: 3, Offset: 13}},
+		{"+", token.Position{Filename: "test.monkey", Line: 2, Column: 5, Offset: 15}},
+		{"a\nb", token.Position{Filename: "test.monkey", Line: 2, Column: 7, Offset: 17}},
+		{";", token.Position{Filename: "test.monkey", Line: 3, Column: 3, Offset: 22}},
+		{"", token.Position{Filename: "test.monkey", Line: 3, Column: 4, Offset: 23}},
+	}
+
+	l := NewWithFilename(input, "test.monkey")
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Position != tt.expectedPosition {
+			t.Fatalf("tests[%d] - position wrong. expected=%+v, got=%+v", i, tt.expectedPosition, tok.Position)
+		}
+	}
+}
+
+func TestNextTokenPositionsWithNonASCII(t *testing.T) {
+	input := `"ééé" + zz;
+"ü" x`
+
+	tests := []struct {
+		expectedLiteral  string
+		expectedPosition token.Position
+	}{
+		{"ééé", token.Position{Line: 1, Column: 1, Offset: 0}},
+		{"+", token.Position{Line: 1, Column: 7, Offset: 9}},
+		{"zz", token.Position{Line: 1, Column: 9, Offset: 11}},
+		{";", token.Position{Line: 1, Column: 11, Offset: 13}},
+		{"ü", token.Position{Line: 2, Column: 1, Offset: 15}},
+		{"x", token.Position{Line: 2, Column: 5, Offset: 20}},
+		{"", token.Position{Line: 2, Column: 6, Offset: 21}},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Position != tt.expectedPosition {
+			t.Fatalf("tests[%d] - position wrong. expected=%+v, got=%+v", i, tt.expectedPosition, tok.Position)
+		}
+	}
+}
+func TestLoopKeywords(t *testing.T) {
+	input := `while (true) { break; continue; } for (x in y) {}`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.WHILE, "while"},
+		{token.OPENPARENTHESIS, "("},
+		{token.TRUE, "true"},
+		{token.CLOSEPARENTHESIS, ")"},
+		{token.OPENBRACE, "{"},
+		{token.BREAK, "break"},
+		{token.SEMICOLON, ";"},
+		{token.CONTINUE, "continue"},
+		{token.SEMICOLON, ";"},
+		{token.CLOSEBRACE, "}"},
+		{token.FOR, "for"},
+		{token.OPENPARENTHESIS, "("},
+		{token.IDENTIFIER, "x"},
+		{token.IN, "in"},
+		{token.IDENTIFIER, "y"},
+		{token.CLOSEPARENTHESIS, ")"},
+		{token.OPENBRACE, "{"},
+		{token.CLOSEBRACE, "}"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestAssignmentOperators(t *testing.T) {
+	input := `x = 1; x += 2; x -= -3;`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENTIFIER, "x"},
+		{token.ASSIGNMENT, "="},
+		{token.INTEGER, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENTIFIER, "x"},
+		{token.PLUSASSIGNMENT, "+="},
+		{token.INTEGER, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENTIFIER, "x"},
+		{token.MINUSASSIGNMENT, "-="},
+		{token.MINUS, "-"},
+		{token.INTEGER, "3"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestComparisonAndModuloOperators(t *testing.T) {
+	input := `a <= b >= c % d < e > f`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENTIFIER, "a"},
+		{token.LESSTHANOREQUAL, "<="},
+		{token.IDENTIFIER, "b"},
+		{token.GREATERTHANOREQUAL, ">="},
+		{token.IDENTIFIER, "c"},
+		{token.PERCENT, "%"},
+		{token.IDENTIFIER, "d"},
+		{token.LESSTHAN, "<"},
+		{token.IDENTIFIER, "e"},
+		{token.GREATERTHAN, ">"},
+		{token.IDENTIFIER, "f"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestLogicalOperators(t *testing.T) {
+	input := `a && b || c & |`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENTIFIER, "a"},
+		{token.AND, "&&"},
+		{token.IDENTIFIER, "b"},
+		{token.OR, "||"},
+		{token.IDENTIFIER, "c"},
+		{token.ILLEGAL, "&"},
+		{token.ILLEGAL, "|"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	input := `5 1.5 0.25 1e3 2.5E-2 7e+1 3.foo 4e x`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INTEGER, "5"},
+		{token.FLOAT, "1.5"},
+		{token.FLOAT, "0.25"},
+		{token.FLOAT, "1e3"},
+		{token.FLOAT, "2.5E-2"},
+		{token.FLOAT, "7e+1"},
+		{token.INTEGER, "3"},
+		{token.ILLEGAL, "."},
+		{token.IDENTIFIER, "foo"},
+		{token.INTEGER, "4"},
+		{token.IDENTIFIER, "e"},
+		{token.IDENTIFIER, "x"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestStrings(t *testing.T) {
+	input := `"a\nb" "tab\there" "say \"hi\"" "back\\slash" "\u{48}\u{e9}\u{1F600}" "héllo"
+	` + "`raw \\n \"string\"\nspanning lines` ``"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRING, "a\nb"},
+		{token.STRING, "tab\there"},
+		{token.STRING, `say "hi"`},
+		{token.STRING, `back\slash`},
+		{token.STRING, "Hé😀"},
+		{token.STRING, "héllo"},
+		{token.STRING, "raw \\n \"string\"\nspanning lines"},
+		{token.STRING, ""},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+
+	if len(l.Errors()) != 0 {
+		t.Errorf("lexer has %d errors: %v", len(l.Errors()), l.Errors())
+	}
+}
+
+func TestMalformedStrings(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedLiteral string
+		expectedError   string
+	}{
+		{`"abc`, `"abc`, "1:1: unterminated string literal"},
+		{`"abc\"`, `"abc\"`, "1:1: unterminated string literal"},
+		{"`abc", "`abc", "1:1: unterminated raw string literal"},
+		{`x "a\qb"`, `"a\qb"`, "1:3: invalid escape sequence \\q"},
+		{`"\u{110000}"`, `"\u{110000}"`, "1:1: invalid unicode escape sequence"},
+		{`"\u{D800}"`, `"\u{D800}"`, "1:1: invalid unicode escape sequence"},
+		{`"\u41"`, `"\u41"`, "1:1: invalid unicode escape sequence"},
+		{`"\u{}"`, `"\u{}"`, "1:1: invalid unicode escape sequence"},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+
+		tok := l.NextToken()
+		for tok.Type != token.ILLEGAL && tok.Type != token.EOF {
+			tok = l.NextToken()
+		}
+
+		if tok.Type != token.ILLEGAL {
+			t.Errorf("%q: expected an ILLEGAL token", tt.input)
+			continue
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Errorf("%q: literal wrong. expected=%q, got=%q", tt.input, tt.expectedLiteral, tok.Literal)
+		}
+
+		if len(l.Errors()) != 1 {
+			t.Errorf("%q: expected 1 lexer error, got=%d", tt.input, len(l.Errors()))
+			continue
+		}
+
+		if l.Errors()[0].Error() != tt.expectedError {
+			t.Errorf("%q: wrong error. expected=%q, got=%q", tt.input, tt.expectedError, l.Errors()[0].Error())
+		}
+
+		if next := l.NextToken(); next.Type != token.EOF {
+			t.Errorf("%q: expected EOF after the malformed string, got=%q", tt.input, next.Type)
+		}
+	}
+}
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	input := `let größe = 5; π * größe; 名前 é`
+
+	tests := []struct {
+		expectedType     token.TokenType
+		expectedLiteral  string
+		expectedPosition token.Position
+	}{
+		{token.LET, "let", token.Position{Line: 1, Column: 1, Offset: 0}},
+		{token.IDENTIFIER, "größe", token.Position{Line: 1, Column: 5, Offset: 4}},
+		{token.ASSIGNMENT, "=", token.Position{Line: 1, Column: 11, Offset: 12}},
+		{token.INTEGER, "5", token.Position{Line: 1, Column: 13, Offset: 14}},
+		{token.SEMICOLON, ";", token.Position{Line: 1, Column: 14, Offset: 15}},
+		{token.IDENTIFIER, "π", token.Position{Line: 1, Column: 16, Offset: 17}},
+		{token.ASTERISK, "*", token.Position{Line: 1, Column: 18, Offset: 20}},
+		{token.IDENTIFIER, "größe", token.Position{Line: 1, Column: 20, Offset: 22}},
+		{token.SEMICOLON, ";", token.Position{Line: 1, Column: 25, Offset: 29}},
+		{token.IDENTIFIER, "名前", token.Position{Line: 1, Column: 27, Offset: 31}},
+		{token.IDENTIFIER, "é", token.Position{Line: 1, Column: 30, Offset: 38}},
+		{token.EOF, "", token.Position{Line: 1, Column: 31, Offset: 40}},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Position != tt.expectedPosition {
+			t.Fatalf("tests[%d] - position wrong. expected=%+v, got=%+v", i, tt.expectedPosition, tok.Position)
+		}
+	}
+}
+
+func TestInterpolatedStrings(t *testing.T) {
+	input := `"a ${x} b ${ {"k": "${y}"}["k"] } c" "${z}"`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRINGHEAD, "a "},
+		{token.IDENTIFIER, "x"},
+		{token.STRINGMIDDLE, " b "},
+		{token.OPENBRACE, "{"},
+		{token.STRING, "k"},
+		{token.COLON, ":"},
+		{token.STRINGHEAD, ""},
+		{token.IDENTIFIER, "y"},
+		{token.STRINGTAIL, ""},
+		{token.CLOSEBRACE, "}"},
+		{token.OPENBRACKET, "["},
+		{token.STRING, "k"},
+		{token.CLOSEBRACKET, "]"},
+		{token.STRINGTAIL, " c"},
+		{token.STRINGHEAD, ""},
+		{token.IDENTIFIER, "z"},
+		{token.STRINGTAIL, ""},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `#!/usr/bin/env monkey
+let x = 5; // five
+# a shell style comment
+x /* inline */ / 2 /* outer /* nested */ still outer */ * 3
+//`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENTIFIER, "x"},
+		{token.ASSIGNMENT, "="},
+		{token.INTEGER, "5"},
+		{token.SEMICOLON, ";"},
+		{token.IDENTIFIER, "x"},
+		{token.SLASH, "/"},
+		{token.INTEGER, "2"},
+		{token.ASTERISK, "*"},
+		{token.INTEGER, "3"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+
+	expectedComments := []struct {
+		literal  string
+		position token.Position
+	}{
+		{"#!/usr/bin/env monkey", token.Position{Line: 1, Column: 1, Offset: 0}},
+		{"// five", token.Position{Line: 2, Column: 12, Offset: 33}},
+		{"# a shell style comment", token.Position{Line: 3, Column: 1, Offset: 41}},
+		{"/* inline */", token.Position{Line: 4, Column: 3, Offset: 67}},
+		{"/* outer /* nested */ still outer */", token.Position{Line: 4, Column: 20, Offset: 84}},
+		{"//", token.Position{Line: 5, Column: 1, Offset: 125}},
+	}
+
+	comments := l.Comments()
+	if len(comments) != len(expectedComments) {
+		t.Fatalf("wrong number of comments. expected=%d, got=%d", len(expectedComments), len(comments))
+	}
+
+	for i, expected := range expectedComments {
+		if comments[i].Type != token.COMMENT {
+			t.Errorf("comments[%d] - tokentype wrong. expected=%q, got=%q", i, token.COMMENT, comments[i].Type)
+		}
+
+		if comments[i].Literal != expected.literal {
+			t.Errorf("comments[%d] - literal wrong. expected=%q, got=%q", i, expected.literal, comments[i].Literal)
+		}
+
+		if comments[i].Position != expected.position {
+			t.Errorf("comments[%d] - position wrong. expected=%+v, got=%+v", i, expected.position, comments[i].Position)
+		}
+	}
+}
+
+func TestUnterminatedBlockComment(t *testing.T) {
+	l := New("x /* outer /* inner */")
+
+	l.NextToken()
+	tok := l.NextToken()
+
+	if tok.Type != token.ILLEGAL || tok.Literal != "/* outer /* inner */" {
+		t.Fatalf("expected an ILLEGAL token for the comment, got=%q (%q)", tok.Type, tok.Literal)
+	}
+
+	if len(l.Errors()) != 1 || l.Errors()[0].Error() != "1:3: unterminated block comment" {
+		t.Fatalf("wrong errors. got=%v", l.Errors())
+	}
+
+	if next := l.NextToken(); next.Type != token.EOF {
+		t.Errorf("expected EOF after the comment, got=%q", next.Type)
+	}
+}
+
+func TestNullOperators(t *testing.T) {
+	input := `null ?? a?[0]?.[1] ?? f?.(x) ? b`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.NULL, "null"},
+		{token.NULLCOALESCE, "??"},
+		{token.IDENTIFIER, "a"},
+		{token.QUESTIONBRACKET, "?["},
+		{token.INTEGER, "0"},
+		{token.CLOSEBRACKET, "]"},
+		{token.QUESTIONDOT, "?."},
+		{token.OPENBRACKET, "["},
+		{token.INTEGER, "1"},
+		{token.CLOSEBRACKET, "]"},
+		{token.NULLCOALESCE, "??"},
+		{token.IDENTIFIER, "f"},
+		{token.QUESTIONDOT, "?."},
+		{token.OPENPARENTHESIS, "("},
+		{token.IDENTIFIER, "x"},
+		{token.CLOSEPARENTHESIS, ")"},
+		{token.ILLEGAL, "?"},
+		{token.IDENTIFIER, "b"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestEllipsis(t *testing.T) {
+	input := `[a, ...rest] .. .`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.OPENBRACKET, "["},
+		{token.IDENTIFIER, "a"},
+		{token.COMMA, ","},
+		{token.ELLIPSIS, "..."},
+		{token.IDENTIFIER, "rest"},
+		{token.CLOSEBRACKET, "]"},
+		{token.ILLEGAL, "."},
+		{token.ILLEGAL, "."},
+		{token.ILLEGAL, "."},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
@@ -1,20 +1,204 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"monkey-lang/compiler"
+	"monkey-lang/evaluator"
+	"monkey-lang/lexer"
+	"monkey-lang/object"
+	"monkey-lang/parser"
 	"monkey-lang/repl"
+	"monkey-lang/vm"
 	"os"
 	"os/user"
 )
 
+// Exit codes reported by the `monkey` command
+const (
+	EXIT_OK            = 0
+	EXIT_USAGE         = 1 // bad arguments or unreadable input
+	EXIT_PARSE_ERROR   = 2
+	EXIT_COMPILE_ERROR = 3
+	EXIT_RUNTIME_ERROR = 4
+)
+
+const USAGE = `Usage:
+  monkey [flags] run <file.monkey>   run a script, use - to read it from stdin
+  monkey [flags] repl                start an interactive session
+  monkey [flags]                     run the program piped to stdin, or start a session
+
+Flags:
+`
+
 func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// The values of the --overflow flag
+var overflowPolicies = map[string]object.IntegerOverflow{
+	"error":   object.OverflowError,
+	"promote": object.OverflowPromote,
+}
+
+func run(args []string, stdin *os.File, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("monkey", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, USAGE)
+		flags.PrintDefaults()
+	}
+	engine := flags.String("engine", repl.ENGINE_VM, "the engine to execute programs with: eval or vm")
+	overflow := flags.String("overflow", "error", "what integer arithmetic does on overflow: error, or promote to big integers")
+
+	if err := flags.Parse(args); err != nil {
+		return EXIT_USAGE
+	}
+
+	// Flags may also follow the command, e.g. `monkey run --engine=eval file.monkey`
+	command := flags.Arg(0)
+	if command != "" {
+		if err := flags.Parse(flags.Args()[1:]); err != nil {
+			return EXIT_USAGE
+		}
+	}
+
+	if *engine != repl.ENGINE_VM && *engine != repl.ENGINE_EVAL {
+		fmt.Fprintf(stderr, "unknown engine %q\n", *engine)
+		flags.Usage()
+		return EXIT_USAGE
+	}
+
+	integerOverflow, ok := overflowPolicies[*overflow]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown overflow policy %q\n", *overflow)
+		flags.Usage()
+		return EXIT_USAGE
+	}
+
+	config := repl.Config{Engine: *engine, IntegerOverflow: integerOverflow}
+
+	switch command {
+	case "run":
+		if flags.NArg() != 1 {
+			flags.Usage()
+			return EXIT_USAGE
+		}
+
+		var source []byte
+		var err error
+		filename := flags.Arg(0)
+		if filename == "-" {
+			filename = "<stdin>"
+			source, err = ioutil.ReadAll(stdin)
+		} else {
+			source, err = ioutil.ReadFile(filename)
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return EXIT_USAGE
+		}
+
+		return execute(string(source), filename, config, stdout, stderr)
+	case "repl":
+		startRepl(stdin, stdout, config)
+		return EXIT_OK
+	case "":
+		if isTerminal(stdin) {
+			startRepl(stdin, stdout, config)
+			return EXIT_OK
+		}
+
+		source, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return EXIT_USAGE
+		}
+
+		return execute(string(source), "<stdin>", config, stdout, stderr)
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n", command)
+		flags.Usage()
+		return EXIT_USAGE
+	}
+}
+
+func startRepl(in io.Reader, out io.Writer, config repl.Config) {
 	user, err := user.Current()
 
 	if err != nil {
 		panic(err)
 	}
 
-	fmt.Printf("Hello %s! This is Monkey-lang!\n", user.Username)
-	fmt.Printf("Feel free to type commands.\n")
-	repl.Start(os.Stdin, os.Stdout)
+	fmt.Fprintf(out, "Hello %s! This is Monkey-lang!\n", user.Username)
+	fmt.Fprintf(out, "Feel free to type commands.\n")
+	repl.StartWithConfig(in, out, config)
+}
+
+// execute runs a whole program, writing its output to stdout and reporting
+// errors to stderr, and returns the exit code
+func execute(source string, filename string, config repl.Config, stdout, stderr io.Writer) int {
+	lexer := lexer.NewWithFilename(source, filename)
+	parser := parser.New(lexer)
+
+	program := parser.ParseProgram()
+	if len(parser.Errors()) != 0 {
+		for _, err := range parser.Errors() {
+			fmt.Fprintf(stderr, "parser error: %s\n", err)
+		}
+		return EXIT_PARSE_ERROR
+	}
+
+	if config.Engine == repl.ENGINE_EVAL {
+		environment := object.NewEnvironment()
+		environment.SetIntegerOverflow(config.IntegerOverflow)
+		environment.SetOutput(stdout)
+
+		evaluated := evaluator.Eval(program, environment)
+		if errorObject, ok := evaluated.(*object.Error); ok {
+			fmt.Fprintf(stderr, "runtime error: %s: %s\n", errorObject.Position, errorObject.Message)
+			printStackTrace(stderr, errorObject.StackTrace)
+			return EXIT_RUNTIME_ERROR
+		}
+
+		return EXIT_OK
+	}
+
+	compiler := compiler.New()
+	err := compiler.Compile(program)
+	if err != nil {
+		fmt.Fprintf(stderr, "compile error: %s\n", err)
+		return EXIT_COMPILE_ERROR
+	}
+
+	machine := vm.New(compiler.Bytecode())
+	machine.SetIntegerOverflow(config.IntegerOverflow)
+	machine.SetOutput(stdout)
+	err = machine.Run()
+	if err != nil {
+		fmt.Fprintf(stderr, "runtime error: %s\n", err)
+		if runtimeError, ok := err.(*vm.RuntimeError); ok {
+			printStackTrace(stderr, runtimeError.StackTrace)
+		}
+		return EXIT_RUNTIME_ERROR
+	}
+
+	return EXIT_OK
+}
+
+func printStackTrace(out io.Writer, trace []object.StackFrame) {
+	for _, frame := range trace {
+		fmt.Fprintf(out, "\t%s\n", frame)
+	}
+}
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
 }
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunExitCodes(t *testing.T) {
+	tests := []struct {
+		source       string
+		expectedCode int
+	}{
+		{"let a = 5; a * 2;", EXIT_OK},
+		{"let = 5;", EXIT_PARSE_ERROR},
+		{"len(1)", EXIT_RUNTIME_ERROR},
+		{"-true", EXIT_RUNTIME_ERROR},
+		{"1 / 0", EXIT_RUNTIME_ERROR},
+		{"9223372036854775807 + 1", EXIT_RUNTIME_ERROR},
+	}
+
+	directory, err := ioutil.TempDir("", "monkey")
+	if err != nil {
+		t.Fatalf("could not create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(directory)
+
+	for _, engine := range []string{"vm", "eval"} {
+		for i, tt := range tests {
+			path := filepath.Join(directory, "script.monkey")
+			err := ioutil.WriteFile(path, []byte(tt.source), 0644)
+			if err != nil {
+				t.Fatalf("could not write script: %s", err)
+			}
+
+			var stdout, stderr bytes.Buffer
+			code := run([]string{"--engine=" + engine, "run", path}, os.Stdin, &stdout, &stderr)
+			if code != tt.expectedCode {
+				t.Errorf("test[%d] (%s) wrong exit code. expected=%d, got=%d (%q)", i, engine, tt.expectedCode, code, stderr.String())
+			}
+		}
+	}
+}
+
+func TestRunCompileErrorExitCode(t *testing.T) {
+	source, err := ioutil.TempFile("", "monkey")
+	if err != nil {
+		t.Fatalf("could not create temporary file: %s", err)
+	}
+	defer os.Remove(source.Name())
+
+	source.WriteString("undefined")
+	source.Seek(0, 0)
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"run", "--engine=vm", "-"}, source, &stdout, &stderr)
+	if code != EXIT_COMPILE_ERROR {
+		t.Errorf("wrong exit code. expected=%d, got=%d (%q)", EXIT_COMPILE_ERROR, code, stderr.String())
+	}
+}
+
+func TestRunOverflowPromote(t *testing.T) {
+	for _, engine := range []string{"vm", "eval"} {
+		source, err := ioutil.TempFile("", "monkey")
+		if err != nil {
+			t.Fatalf("could not create temporary file: %s", err)
+		}
+		defer os.Remove(source.Name())
+
+		source.WriteString("let big = 9223372036854775807 * 4; if (big / 4 != 9223372036854775807) { -true }")
+		source.Seek(0, 0)
+
+		var stdout, stderr bytes.Buffer
+		code := run([]string{"--overflow=promote", "--engine=" + engine, "run", "-"}, source, &stdout, &stderr)
+		if code != EXIT_OK {
+			t.Errorf("(%s) wrong exit code. expected=%d, got=%d (%q)", engine, EXIT_OK, code, stderr.String())
+		}
+	}
+}
+
+func TestRunUsageErrors(t *testing.T) {
+	tests := [][]string{
+		{"--engine=jit", "repl"},
+		{"--overflow=wrap", "repl"},
+		{"run"},
+		{"run", "does-not-exist.monkey"},
+		{"compile", "file.monkey"},
+	}
+
+	for _, args := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run(args, os.Stdin, &stdout, &stderr)
+		if code != EXIT_USAGE {
+			t.Errorf("%v: wrong exit code. expected=%d, got=%d", args, EXIT_USAGE, code)
+		}
+	}
+}
+
+func TestRunWritesOutputToStdout(t *testing.T) {
+	for _, engine := range []string{"vm", "eval"} {
+		source, err := ioutil.TempFile("", "monkey")
+		if err != nil {
+			t.Fatalf("could not create temporary file: %s", err)
+		}
+		defer os.Remove(source.Name())
+
+		source.WriteString(`puts("hello", 1 + 2)`)
+		source.Seek(0, 0)
+
+		var stdout, stderr bytes.Buffer
+		code := run([]string{"--engine=" + engine, "run", "-"}, source, &stdout, &stderr)
+		if code != EXIT_OK {
+			t.Errorf("(%s) wrong exit code. expected=%d, got=%d (%q)", engine, EXIT_OK, code, stderr.String())
+		}
+
+		if stdout.String() != "hello\n3\n" {
+			t.Errorf("(%s) wrong output. expected=%q, got=%q", engine, "hello\n3\n", stdout.String())
+		}
+	}
+}
+
+func TestRunStringEqualityOnBothEngines(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected string
+	}{
+		{`puts("a" == "a")`, "true\n"},
+		{`puts("a" != "a")`, "false\n"},
+		{`puts("a" == "b")`, "false\n"},
+		{`let s = "mon"; puts(s + "key" == "monkey")`, "true\n"},
+	}
+
+	directory, err := ioutil.TempDir("", "monkey")
+	if err != nil {
+		t.Fatalf("could not create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(directory)
+
+	for _, engine := range []string{"vm", "eval"} {
+		for i, tt := range tests {
+			path := filepath.Join(directory, "script.monkey")
+			err := ioutil.WriteFile(path, []byte(tt.source), 0644)
+			if err != nil {
+				t.Fatalf("could not write script: %s", err)
+			}
+
+			var stdout, stderr bytes.Buffer
+			code := run([]string{"--engine=" + engine, "run", path}, os.Stdin, &stdout, &stderr)
+			if code != EXIT_OK {
+				t.Errorf("test[%d] (%s) wrong exit code. expected=%d, got=%d (%q)", i, engine, EXIT_OK, code, stderr.String())
+			}
+			if stdout.String() != tt.expected {
+				t.Errorf("test[%d] (%s) wrong output. expected=%q, got=%q", i, engine, tt.expected, stdout.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package object
+
+import (
+	"math"
+	"math/big"
+)
+
+// IntegerOverflow selects what integer arithmetic does when a result doesn't fit in an int64
+type IntegerOverflow int
+
+const (
+	OverflowError   IntegerOverflow = iota // raise an error, the default
+	OverflowPromote                        // promote the result to a BigInteger
+)
+
+// IsInteger reports whether obj is an Integer or a BigInteger
+func IsInteger(obj Object) bool {
+	return obj.Type() == INTEGER_OBJ || obj.Type() == BIG_INTEGER_OBJ
+}
+
+// IsNumber reports whether obj is an integer of either size or a Float
+func IsNumber(obj Object) bool {
+	return IsInteger(obj) || obj.Type() == FLOAT_OBJ
+}
+
+// ToFloat converts a number to a float64, big integers may lose precision
+func ToFloat(number Object) float64 {
+	switch number := number.(type) {
+	case *Integer:
+		return float64(number.Value)
+	case *BigInteger:
+		value, _ := new(big.Float).SetInt(number.Value).Float64()
+		return value
+	default:
+		return number.(*Float).Value
+	}
+}
+
+// IntegerArithmetic applies one of the operators + - * / % to two integers,
+// either of which may be a BigInteger. Results that don't fit in an int64 are
+// handled according to overflow, and dividing by zero returns an Error
+func IntegerArithmetic(operator string, left, right Object, overflow IntegerOverflow) Object {
+	if (operator == "/" || operator == "%") && isZero(right) {
+		return newError("division by zero")
+	}
+
+	leftInteger, leftOk := left.(*Integer)
+	rightInteger, rightOk := right.(*Integer)
+	if leftOk && rightOk {
+		if result, ok := checkedArithmetic(operator, leftInteger.Value, rightInteger.Value); ok {
+			return &Integer{Value: result}
+		}
+
+		if overflow == OverflowError {
+			return newError("integer overflow: %d %s %d", leftInteger.Value, operator, rightInteger.Value)
+		}
+	}
+
+	leftValue := toBigInt(left)
+	rightValue := toBigInt(right)
+	result := new(big.Int)
+
+	switch operator {
+	case "+":
+		result.Add(leftValue, rightValue)
+	case "-":
+		result.Sub(leftValue, rightValue)
+	case "*":
+		result.Mul(leftValue, rightValue)
+	case "/":
+		// Quo and Rem truncate like Go's int64 operators do
+		result.Quo(leftValue, rightValue)
+	case "%":
+		result.Rem(leftValue, rightValue)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+
+	return normalizeBigInt(result)
+}
+
+// NegateInteger negates an integer, handling overflow like IntegerArithmetic
+func NegateInteger(operand Object, overflow IntegerOverflow) Object {
+	if integer, ok := operand.(*Integer); ok {
+		if integer.Value != math.MinInt64 {
+			return &Integer{Value: -integer.Value}
+		}
+
+		if overflow == OverflowError {
+			return newError("integer overflow: -(%d)", integer.Value)
+		}
+	}
+
+	return normalizeBigInt(new(big.Int).Neg(toBigInt(operand)))
+}
+
+// CompareIntegers returns -1, 0 or 1 depending on whether left is less than,
+// equal to or greater than right
+func CompareIntegers(left, right Object) int {
+	leftInteger, leftOk := left.(*Integer)
+	rightInteger, rightOk := right.(*Integer)
+	if leftOk && rightOk {
+		switch {
+		case leftInteger.Value < rightInteger.Value:
+			return -1
+		case leftInteger.Value > rightInteger.Value:
+			return 1
+		default:
+			return 0
+		}
+	}
+
+	return toBigInt(left).Cmp(toBigInt(right))
+}
+
+// checkedArithmetic computes left operator right, returning false if the result overflows
+func checkedArithmetic(operator string, left, right int64) (int64, bool) {
+	switch operator {
+	case "+":
+		result := left + right
+		return result, (left >= 0) != (right >= 0) || (result >= 0) == (left >= 0)
+	case "-":
+		result := left - right
+		return result, (left >= 0) == (right >= 0) || (result >= 0) == (left >= 0)
+	case "*":
+		if left == 0 || right == 0 {
+			return 0, true
+		}
+		result := left * right
+		if (left == -1 && right == math.MinInt64) || (right == -1 && left == math.MinInt64) {
+			return 0, false
+		}
+		return result, result/right == left
+	case "/":
+		if left == math.MinInt64 && right == -1 {
+			return 0, false
+		}
+		return left / right, true
+	case "%":
+		return left % right, true
+	default:
+		return 0, false
+	}
+}
+
+func isZero(obj Object) bool {
+	switch obj := obj.(type) {
+	case *Integer:
+		return obj.Value == 0
+	case *BigInteger:
+		return obj.Value.Sign() == 0
+	default:
+		return false
+	}
+}
+
+func toBigInt(obj Object) *big.Int {
+	if integer, ok := obj.(*Integer); ok {
+		return big.NewInt(integer.Value)
+	}
+
+	return obj.(*BigInteger).Value
+}
+
+// normalizeBigInt returns an Integer for results that fit in one, so that
+// every value has a single representation
+func normalizeBigInt(value *big.Int) Object {
+	if value.IsInt64() {
+		return &Integer{Value: value.Int64()}
+	}
+
+	return &BigInteger{Value: value}
+}
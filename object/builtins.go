@@ -0,0 +1,267 @@
+package object
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Builtins is the registry of builtin functions shared by the evaluator and the VM.
+// The compiler refers to builtins by their index, so new entries must only be appended.
+var Builtins = []struct {
+	Name    string
+	Builtin *Builtin
+}{
+	{
+		"len",
+		&Builtin{
+			Function: func(out io.Writer, args ...Object) Object {
+				if len(args) != 1 {
+					return newError("Invalid amount of arguments. Expected=%d, got=%d", 1, len(args))
+				}
+
+				switch arg := args[0].(type) {
+				case *String:
+					return &Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+				case *Array:
+					return &Integer{Value: int64(len(arg.Elements))}
+				default:
+					return newError("Invalid argument passed to `len()`. Got=%s", args[0].Type())
+				}
+			},
+		},
+	},
+	{
+		"puts",
+		&Builtin{
+			Function: func(out io.Writer, args ...Object) Object {
+				for _, arg := range args {
+					fmt.Fprintln(out, arg.Inspect())
+				}
+
+				return nil
+			},
+		},
+	},
+	{
+		"first",
+		&Builtin{
+			Function: func(out io.Writer, args ...Object) Object {
+				if len(args) != 1 {
+					return newError("Invalid amount of arguments. Expected=%d, got=%d", 1, len(args))
+				}
+
+				if args[0].Type() != ARRAY_OBJ {
+					return newError("Invalid argument passed to `first()`. Expected=ARRAY, got=%s", args[0].Type())
+				}
+
+				array := args[0].(*Array)
+				if len(array.Elements) > 0 {
+					return array.Elements[0]
+				}
+
+				return nil
+			},
+		},
+	},
+	{
+		"last",
+		&Builtin{
+			Function: func(out io.Writer, args ...Object) Object {
+				if len(args) != 1 {
+					return newError("Invalid amount of arguments. Expected=%d, got=%d", 1, len(args))
+				}
+
+				if args[0].Type() != ARRAY_OBJ {
+					return newError("Invalid argument passed to `last()`. Expected=ARRAY, got=%s", args[0].Type())
+				}
+
+				array := args[0].(*Array)
+				length := len(array.Elements)
+				if length > 0 {
+					return array.Elements[length-1]
+				}
+
+				return nil
+			},
+		},
+	},
+	{
+		"rest",
+		&Builtin{
+			Function: func(out io.Writer, args ...Object) Object {
+				if len(args) != 1 {
+					return newError("Invalid amount of arguments. Expected=%d, got=%d", 1, len(args))
+				}
+
+				if args[0].Type() != ARRAY_OBJ {
+					return newError("Invalid argument passed to `rest()`. Expected=ARRAY, got=%s", args[0].Type())
+				}
+
+				array := args[0].(*Array)
+				length := len(array.Elements)
+				if length > 0 {
+					newElements := make([]Object, length-1, length-1)
+					copy(newElements, array.Elements[1:length])
+					return &Array{Elements: newElements}
+				}
+
+				return nil
+			},
+		},
+	},
+	{
+		"push",
+		&Builtin{
+			Function: func(out io.Writer, args ...Object) Object {
+				if len(args) != 2 {
+					return newError("Invalid amount of arguments. Expected=%d, got=%d", 2, len(args))
+				}
+
+				if args[0].Type() != ARRAY_OBJ {
+					return newError("Invalid argument passed to `push()`. Expected=ARRAY, got=%s", args[0].Type())
+				}
+
+				array := args[0].(*Array)
+				length := len(array.Elements)
+
+				newElements := make([]Object, length+1, length+1)
+				copy(newElements, array.Elements)
+				newElements[length] = args[1]
+
+				return &Array{Elements: newElements}
+			},
+		},
+	},
+	{
+		"int",
+		&Builtin{
+			Function: func(out io.Writer, args ...Object) Object {
+				if len(args) != 1 {
+					return newError("Invalid amount of arguments. Expected=%d, got=%d", 1, len(args))
+				}
+
+				switch arg := args[0].(type) {
+				case *Integer, *BigInteger:
+					return arg
+				case *Float:
+					return floatToInteger(math.Trunc(arg.Value))
+				case *String:
+					value, err := strconv.ParseInt(arg.Value, 0, 64)
+					if err != nil {
+						return newError("could not parse %q as integer", arg.Value)
+					}
+					return &Integer{Value: value}
+				default:
+					return newError("Invalid argument passed to `int()`. Got=%s", args[0].Type())
+				}
+			},
+		},
+	},
+	{
+		"float",
+		&Builtin{
+			Function: func(out io.Writer, args ...Object) Object {
+				if len(args) != 1 {
+					return newError("Invalid amount of arguments. Expected=%d, got=%d", 1, len(args))
+				}
+
+				switch arg := args[0].(type) {
+				case *Integer, *BigInteger:
+					return &Float{Value: ToFloat(arg)}
+				case *Float:
+					return arg
+				case *String:
+					value, err := strconv.ParseFloat(arg.Value, 64)
+					if err != nil {
+						return newError("could not parse %q as float", arg.Value)
+					}
+					return &Float{Value: value}
+				default:
+					return newError("Invalid argument passed to `float()`. Got=%s", args[0].Type())
+				}
+			},
+		},
+	},
+	{
+		"floor",
+		&Builtin{
+			Function: func(out io.Writer, args ...Object) Object {
+				if len(args) != 1 {
+					return newError("Invalid amount of arguments. Expected=%d, got=%d", 1, len(args))
+				}
+
+				switch arg := args[0].(type) {
+				case *Integer, *BigInteger:
+					return arg
+				case *Float:
+					return floatToInteger(math.Floor(arg.Value))
+				default:
+					return newError("Invalid argument passed to `floor()`. Got=%s", args[0].Type())
+				}
+			},
+		},
+	},
+	{
+		"round",
+		&Builtin{
+			Function: func(out io.Writer, args ...Object) Object {
+				if len(args) != 1 {
+					return newError("Invalid amount of arguments. Expected=%d, got=%d", 1, len(args))
+				}
+
+				switch arg := args[0].(type) {
+				case *Integer, *BigInteger:
+					return arg
+				case *Float:
+					return floatToInteger(math.Round(arg.Value))
+				default:
+					return newError("Invalid argument passed to `round()`. Got=%s", args[0].Type())
+				}
+			},
+		},
+	},
+	{
+		"freeze",
+		&Builtin{
+			Function: func(out io.Writer, args ...Object) Object {
+				if len(args) != 1 {
+					return newError("Invalid amount of arguments. Expected=%d, got=%d", 1, len(args))
+				}
+
+				switch args[0].(type) {
+				case *Array, *Hash:
+					return Freeze(args[0])
+				default:
+					return newError("Invalid argument passed to `freeze()`. Expected=ARRAY or HASH, got=%s", args[0].Type())
+				}
+			},
+		},
+	},
+}
+
+// GetBuiltinByName returns the builtin registered under name, or nil if there is none
+func GetBuiltinByName(name string) *Builtin {
+	for _, definition := range Builtins {
+		if definition.Name == name {
+			return definition.Builtin
+		}
+	}
+
+	return nil
+}
+
+// floatToInteger converts a whole float to an Integer, failing if it doesn't fit in one
+func floatToInteger(value float64) Object {
+	if math.IsNaN(value) || value < math.MinInt64 || value >= math.MaxInt64 {
+		return newError("%s is out of the integer range", (&Float{Value: value}).Inspect())
+	}
+
+	return &Integer{Value: int64(value)}
+}
+
+func newError(format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...)}
+}
@@ -1,13 +1,23 @@
 package object
 
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	store     map[string]Object
+	constants map[string]bool // the names in store bound with const
+	outer     *Environment
+
+	integerOverflow IntegerOverflow // only set on the outermost environment
+	output          io.Writer       // only set on the outermost environment, standard output when nil
 }
 
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
-	return &Environment{store: s, outer: nil}
+	return &Environment{store: s, constants: make(map[string]bool), outer: nil}
 }
 
 func NewEnclosedEnvironment(outer *Environment) *Environment {
@@ -26,7 +36,90 @@ func (environment *Environment) Get(name string) (Object, bool) {
 	return object, ok
 }
 
+// Declare reserves name in this scope without binding it yet. Until it is set,
+// Get finds it with a nil value rather than looking in the enclosing scopes
+func (environment *Environment) Declare(name string) {
+	environment.store[name] = nil
+}
+
 func (enviornment *Environment) Set(name string, value Object) Object {
 	enviornment.store[name] = value
+	delete(enviornment.constants, name)
 	return value
 }
+
+// SetConstant binds name in this scope like Set, but so that it can't be
+// reassigned
+func (environment *Environment) SetConstant(name string, value Object) Object {
+	environment.store[name] = value
+	environment.constants[name] = true
+	return value
+}
+
+// IsConstant reports whether name is bound with SetConstant in this scope,
+// not looking at the enclosing ones
+func (environment *Environment) IsConstant(name string) bool {
+	return environment.constants[name]
+}
+
+// Assign rebinds name in the innermost scope it is defined in, failing if it
+// isn't defined in any or is a constant there
+func (environment *Environment) Assign(name string, value Object) (Object, error) {
+	if _, ok := environment.store[name]; ok {
+		if environment.constants[name] {
+			return nil, fmt.Errorf("cannot assign to constant %s", name)
+		}
+
+		environment.store[name] = value
+		return value, nil
+	}
+
+	if environment.outer != nil {
+		return environment.outer.Assign(name, value)
+	}
+
+	return nil, fmt.Errorf("assignment to undeclared variable %s", name)
+}
+
+// IntegerOverflow returns what integer arithmetic evaluated in this environment does on overflow
+func (environment *Environment) IntegerOverflow() IntegerOverflow {
+	if environment.outer != nil {
+		return environment.outer.IntegerOverflow()
+	}
+
+	return environment.integerOverflow
+}
+
+// SetIntegerOverflow sets what integer arithmetic does on overflow for the
+// whole interpreter the environment belongs to
+func (environment *Environment) SetIntegerOverflow(overflow IntegerOverflow) {
+	if environment.outer != nil {
+		environment.outer.SetIntegerOverflow(overflow)
+		return
+	}
+
+	environment.integerOverflow = overflow
+}
+
+// Output returns where builtins like puts() evaluated in this environment write
+func (environment *Environment) Output() io.Writer {
+	if environment.outer != nil {
+		return environment.outer.Output()
+	}
+
+	if environment.output == nil {
+		return os.Stdout
+	}
+	return environment.output
+}
+
+// SetOutput sets where builtins like puts() write for the whole interpreter the
+// environment belongs to
+func (environment *Environment) SetOutput(output io.Writer) {
+	if environment.outer != nil {
+		environment.outer.SetOutput(output)
+		return
+	}
+
+	environment.output = output
+}
@@ -4,23 +4,38 @@ import (
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"io"
+	"math"
+	"math/big"
 	"monkey-lang/ast"
+	"monkey-lang/code"
+	"monkey-lang/token"
+	"sort"
+	"strconv"
 	"strings"
 )
 
 type ObjectType string
 
 const (
-	INTEGER_OBJ          = "INTEGER"
-	BOOLEAN_OBJ          = "BOOLEAN"
-	STRING_OBJ           = "STRING"
-	ARRAY_OBJ            = "ARRAY"
-	NULL_OBJ             = "NULL"
-	RETURN_VALUE_OBJ     = "RETURN_VALUE"
-	ERROR_OBJ            = "ERROR"
-	FUNCTION_OBJ         = "FUNCTION"
-	BUILTIN_FUNCTION_OBJ = "BUILTIN_FUNCTION"
-	HASH_OBJ             = "HASH"
+	INTEGER_OBJ           = "INTEGER"
+	BIG_INTEGER_OBJ       = "BIG_INTEGER"
+	FLOAT_OBJ             = "FLOAT"
+	BOOLEAN_OBJ           = "BOOLEAN"
+	STRING_OBJ            = "STRING"
+	ARRAY_OBJ             = "ARRAY"
+	NULL_OBJ              = "NULL"
+	RETURN_VALUE_OBJ      = "RETURN_VALUE"
+	ERROR_OBJ             = "ERROR"
+	FUNCTION_OBJ          = "FUNCTION"
+	BUILTIN_FUNCTION_OBJ  = "BUILTIN_FUNCTION"
+	HASH_OBJ              = "HASH"
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
+	CLOSURE_OBJ           = "CLOSURE"
+	BREAK_OBJ             = "BREAK"
+	CONTINUE_OBJ          = "CONTINUE"
+	ITERATOR_OBJ          = "ITERATOR"
+	CELL_OBJ              = "CELL"
 )
 
 type Object interface {
@@ -35,6 +50,31 @@ type Integer struct {
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 
+// BigInteger holds the integers which don't fit in an Integer, the results of
+// arithmetic are only BigIntegers when they have to be
+type BigInteger struct {
+	Value *big.Int
+}
+
+func (bi *BigInteger) Inspect() string  { return bi.Value.String() }
+func (bi *BigInteger) Type() ObjectType { return BIG_INTEGER_OBJ }
+
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+func (f *Float) Inspect() string {
+	inspected := strconv.FormatFloat(f.Value, 'g', -1, 64)
+
+	// Tell whole floats apart from integers
+	if !strings.ContainsAny(inspected, ".eIN") {
+		inspected += ".0"
+	}
+
+	return inspected
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -54,27 +94,63 @@ type ReturnValue struct {
 func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 
+// Break signals a `break` statement to the enclosing loop
+type Break struct{}
+
+func (b *Break) Inspect() string  { return "break" }
+func (b *Break) Type() ObjectType { return BREAK_OBJ }
+
+// Continue signals a `continue` statement to the enclosing loop
+type Continue struct{}
+
+func (c *Continue) Inspect() string  { return "continue" }
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
+
 type Error struct {
-	Message string
+	Message    string
+	Position   token.Position // where the error was raised, if known
+	StackTrace []StackFrame   // the calls that led to the error, innermost first
 }
 
-func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
+func (e *Error) Inspect() string {
+	if e.Position.IsValid() {
+		return "ERROR: " + e.Position.String() + ": " + e.Message
+	}
+
+	return "ERROR: " + e.Message
+}
+
+// StackFrame is one call of a Monkey function in a stack trace
+type StackFrame struct {
+	Function string         // the name the function is bound to, empty for anonymous functions
+	Position token.Position // where the function was called
+}
+
+func (sf StackFrame) String() string {
+	name := sf.Function
+	if name == "" {
+		name = "<anonymous>"
+	}
+
+	return fmt.Sprintf("in %s, called at %s", name, sf.Position)
+}
 
 type Function struct {
 	Parameters  []*ast.Identifier
+	Defaults    []ast.Expression // the default value of each parameter, nil for required ones
+	NumRequired int              // the number of parameters without a default
+	Rest        *ast.Identifier  // the rest parameter, if any
 	Body        *ast.BlockStatement
 	Environment *Environment
+	Name        string // the name the function is bound to with `let`, if any
 }
 
 func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
 func (f *Function) Inspect() string {
 	var out bytes.Buffer
 
-	params := []string{}
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
-	}
+	params := ast.ParametersString(f.Parameters, f.Defaults, f.Rest)
 
 	out.WriteString("fn")
 	out.WriteString("(")
@@ -86,6 +162,111 @@ func (f *Function) Inspect() string {
 	return out.String()
 }
 
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	NumLocals     int // the number of local bindings the function creates
+	NumParameters int // the number of parameters, not counting a rest parameter
+	NumRequired   int // the number of parameters without a default
+	HasRest       bool
+	Name          string   // the name the function is bound to with `let`, if any
+	LocalNames    []string // the name of each local binding, by index
+	FreeNames     []string // the name of each free variable, by index
+
+	SourceMap map[int]token.Position // maps instruction offsets to the source they were compiled from
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+// CheckArity returns the error message for calling a function that takes
+// numRequired to numParameters arguments, or any number more with a rest
+// parameter, with numArgs arguments. It returns "" if the call is fine
+func CheckArity(numRequired, numParameters int, hasRest bool, numArgs int) string {
+	if numArgs >= numRequired && (numArgs <= numParameters || hasRest) {
+		return ""
+	}
+
+	want := fmt.Sprintf("%d", numRequired)
+	switch {
+	case hasRest:
+		want = fmt.Sprintf("at least %d", numRequired)
+	case numRequired < numParameters:
+		want = fmt.Sprintf("%d to %d", numRequired, numParameters)
+	}
+
+	return fmt.Sprintf("wrong number of arguments: want=%s, got=%d", want, numArgs)
+}
+
+// ArrangeArguments places the named arguments of a call after its positional
+// ones, at the positions of the parameters they name. Parameters skipped by
+// the names get null, so that they take their default. It returns "" as the
+// message unless a name isn't a parameter, a parameter gets more than one
+// argument or a required parameter is skipped
+func ArrangeArguments(parameters []string, numRequired int, positional []Object, names []string, named []Object, null Object) ([]Object, string) {
+	args := make([]Object, len(positional))
+	copy(args, positional)
+
+	for i, name := range names {
+		index := -1
+		for j, parameter := range parameters {
+			if parameter == name {
+				index = j
+				break
+			}
+		}
+
+		if index < 0 {
+			return nil, fmt.Sprintf("unknown parameter %s", name)
+		}
+		if index < len(args) && args[index] != nil {
+			return nil, fmt.Sprintf("parameter %s got more than one argument", name)
+		}
+
+		for len(args) <= index {
+			args = append(args, nil)
+		}
+		args[index] = named[i]
+	}
+
+	for len(args) < numRequired {
+		args = append(args, nil)
+	}
+
+	for i, arg := range args {
+		if arg != nil {
+			continue
+		}
+		if i < numRequired {
+			return nil, fmt.Sprintf("missing argument for parameter %s", parameters[i])
+		}
+		args[i] = null
+	}
+
+	return args, ""
+}
+
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []*Cell // the free variables captured when the closure was created
+}
+
+func (c *Closure) Type() ObjectType { return CLOSURE_OBJ }
+func (c *Closure) Inspect() string {
+	return fmt.Sprintf("Closure[%p]", c)
+}
+
+// Cell holds a variable captured by a closure. The closure and the function
+// defining the variable share the cell, so an assignment made by either one is
+// seen by the other
+type Cell struct {
+	Value Object
+}
+
+func (c *Cell) Type() ObjectType { return CELL_OBJ }
+func (c *Cell) Inspect() string  { return c.Value.Inspect() }
+
 type String struct {
 	Value string
 }
@@ -93,7 +274,9 @@ type String struct {
 func (s *String) Inspect() string  { return s.Value }
 func (s *String) Type() ObjectType { return STRING_OBJ }
 
-type BuiltinFunction func(args ...Object) Object
+// BuiltinFunction implements a builtin. out is where the program's output
+// goes, e.g. what puts() prints
+type BuiltinFunction func(out io.Writer, args ...Object) Object
 
 type Builtin struct {
 	Function BuiltinFunction
@@ -104,6 +287,7 @@ func (s *Builtin) Type() ObjectType { return BUILTIN_FUNCTION_OBJ }
 
 type Array struct {
 	Elements []Object
+	Frozen   bool // set by Freeze, the elements can't be assigned to
 }
 
 func (ao *Array) Type() ObjectType { return ARRAY_OBJ }
@@ -146,6 +330,22 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+func (bi *BigInteger) HashKey() HashKey {
+	hash := fnv.New64a()
+	hash.Write([]byte(bi.Value.String()))
+
+	return HashKey{Type: bi.Type(), Value: hash.Sum64()}
+}
+
+func (f *Float) HashKey() HashKey {
+	// 0.0 and -0.0 are equal, but have different bit patterns
+	if f.Value == 0 {
+		return HashKey{Type: f.Type(), Value: 0}
+	}
+
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
+
 func (s *String) HashKey() HashKey {
 	hash := fnv.New64a()
 	hash.Write([]byte(s.Value))
@@ -161,7 +361,8 @@ type HashPair struct {
 }
 
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	Pairs  map[HashKey]HashPair
+	Frozen bool // set by Freeze, no pairs can be added or changed
 }
 
 func (h *Hash) Type() ObjectType { return HASH_OBJ }
@@ -169,7 +370,7 @@ func (h *Hash) Inspect() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for _, pair := range h.Pairs {
+	for _, pair := range h.SortedPairs() {
 		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
 	}
 
@@ -179,3 +380,166 @@ func (h *Hash) Inspect() string {
 
 	return out.String()
 }
+
+// SortedPairs returns the pairs of the hash ordered by key: keys of the same
+// type are compared by value, keys of different types by type name
+func (h *Hash) SortedPairs() []HashPair {
+	pairs := make([]HashPair, 0, len(h.Pairs))
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair)
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		return hashKeyLess(pairs[i].Key, pairs[j].Key)
+	})
+
+	return pairs
+}
+
+// Freeze makes value read-only along with all the arrays and hashes it
+// contains, and returns it. Copies of frozen values, like those made by
+// push(), aren't frozen
+func Freeze(value Object) Object {
+	switch value := value.(type) {
+	case *Array:
+		if value.Frozen {
+			break
+		}
+		value.Frozen = true
+		for _, element := range value.Elements {
+			Freeze(element)
+		}
+	case *Hash:
+		if value.Frozen {
+			break
+		}
+		value.Frozen = true
+		for _, pair := range value.Pairs {
+			Freeze(pair.Key)
+			Freeze(pair.Value)
+		}
+	}
+
+	return value
+}
+
+// IsFrozen reports whether value is an array or hash made read-only by Freeze
+func IsFrozen(value Object) bool {
+	switch value := value.(type) {
+	case *Array:
+		return value.Frozen
+	case *Hash:
+		return value.Frozen
+	default:
+		return false
+	}
+}
+
+// Without returns a new hash with the pairs of h whose keys aren't among keys
+func (h *Hash) Without(keys []HashKey) *Hash {
+	pairs := make(map[HashKey]HashPair, len(h.Pairs))
+	for hashKey, pair := range h.Pairs {
+		pairs[hashKey] = pair
+	}
+
+	for _, hashKey := range keys {
+		delete(pairs, hashKey)
+	}
+
+	return &Hash{Pairs: pairs}
+}
+
+// From returns a new array with the elements of a from index on, which is
+// empty when a has no more than index elements
+func (a *Array) From(index int) *Array {
+	if index >= len(a.Elements) {
+		return &Array{Elements: []Object{}}
+	}
+
+	elements := make([]Object, len(a.Elements)-index)
+	copy(elements, a.Elements[index:])
+	return &Array{Elements: elements}
+}
+
+func hashKeyLess(a, b Object) bool {
+	if a.Type() != b.Type() {
+		return a.Type() < b.Type()
+	}
+
+	switch a := a.(type) {
+	case *Integer:
+		return a.Value < b.(*Integer).Value
+	case *BigInteger:
+		return a.Value.Cmp(b.(*BigInteger).Value) < 0
+	case *Float:
+		return a.Value < b.(*Float).Value
+	case *String:
+		return a.Value < b.(*String).Value
+	case *Boolean:
+		return !a.Value && b.(*Boolean).Value
+	default:
+		return a.Inspect() < b.Inspect()
+	}
+}
+
+// Iterator walks an Array, a String or a Hash. Arrays and strings yield their
+// elements along with their index, hashes yield their pairs in key order
+type Iterator struct {
+	keys     []Object
+	values   []Object
+	elements []Object // what a loop binding a single name sees
+	position int
+}
+
+// NewIterator returns an iterator over iterable, or false if it can't be iterated
+func NewIterator(iterable Object) (*Iterator, bool) {
+	iterator := &Iterator{}
+
+	switch iterable := iterable.(type) {
+	case *Array:
+		for i, element := range iterable.Elements {
+			iterator.keys = append(iterator.keys, &Integer{Value: int64(i)})
+			iterator.values = append(iterator.values, element)
+		}
+		iterator.elements = iterator.values
+	case *String:
+		for _, r := range []rune(iterable.Value) {
+			iterator.keys = append(iterator.keys, &Integer{Value: int64(len(iterator.keys))})
+			iterator.values = append(iterator.values, &String{Value: string(r)})
+		}
+		iterator.elements = iterator.values
+	case *Hash:
+		for _, pair := range iterable.SortedPairs() {
+			iterator.keys = append(iterator.keys, pair.Key)
+			iterator.values = append(iterator.values, pair.Value)
+		}
+		iterator.elements = iterator.keys
+	default:
+		return nil, false
+	}
+
+	return iterator, true
+}
+
+func (it *Iterator) Type() ObjectType { return ITERATOR_OBJ }
+func (it *Iterator) Inspect() string  { return "iterator" }
+
+// Next advances the iterator, returning false once it is exhausted
+func (it *Iterator) Next() bool {
+	if it.position >= len(it.keys) {
+		return false
+	}
+
+	it.position++
+	return true
+}
+
+// Key returns the index or hash key the iterator is currently at
+func (it *Iterator) Key() Object { return it.keys[it.position-1] }
+
+// Value returns the element or hash value the iterator is currently at
+func (it *Iterator) Value() Object { return it.values[it.position-1] }
+
+// Element returns what a loop binding a single name sees: the element of an
+// array or string, or the key of a hash
+func (it *Iterator) Element() Object { return it.elements[it.position-1] }
@@ -1,6 +1,9 @@
 package object
 
-import "testing"
+import (
+	"math"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -18,3 +21,200 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with different content have the same hash keys")
 	}
 }
+
+func TestHashSortedPairs(t *testing.T) {
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	for _, key := range []Object{
+		&String{Value: "b"}, &Integer{Value: 10}, &Boolean{Value: true},
+		&String{Value: "a"}, &Integer{Value: -2}, &Boolean{Value: false},
+	} {
+		hash.Pairs[key.(Hashable).HashKey()] = HashPair{Key: key, Value: key}
+	}
+
+	expected := []string{"false", "true", "-2", "10", "a", "b"}
+
+	pairs := hash.SortedPairs()
+	if len(pairs) != len(expected) {
+		t.Fatalf("wrong number of pairs. want=%d, got=%d", len(expected), len(pairs))
+	}
+
+	for i, pair := range pairs {
+		if pair.Key.Inspect() != expected[i] {
+			t.Errorf("pairs[%d] has wrong key. want=%q, got=%q", i, expected[i], pair.Key.Inspect())
+		}
+	}
+}
+
+func TestEnvironmentAssign(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("a", &Integer{Value: 1})
+	inner := NewEnclosedEnvironment(outer)
+
+	if _, err := inner.Assign("a", &Integer{Value: 2}); err != nil {
+		t.Fatalf("assigning a name of an outer scope failed: %s", err)
+	}
+
+	value, _ := outer.Get("a")
+	if value.(*Integer).Value != 2 {
+		t.Errorf("the outer binding was not updated. got=%s", value.Inspect())
+	}
+
+	if _, ok := inner.store["a"]; ok {
+		t.Errorf("assigning created a binding in the inner scope")
+	}
+
+	if _, err := inner.Assign("b", &Integer{Value: 3}); err == nil {
+		t.Errorf("assigning an undeclared name succeeded")
+	}
+}
+
+func TestEnvironmentConstants(t *testing.T) {
+	outer := NewEnvironment()
+	outer.SetConstant("c", &Integer{Value: 1})
+	inner := NewEnclosedEnvironment(outer)
+
+	_, err := inner.Assign("c", &Integer{Value: 2})
+	if err == nil || err.Error() != "cannot assign to constant c" {
+		t.Fatalf("wrong error assigning a constant. got=%v", err)
+	}
+
+	value, _ := outer.Get("c")
+	if value.(*Integer).Value != 1 {
+		t.Errorf("the constant was changed. got=%s", value.Inspect())
+	}
+
+	if !outer.IsConstant("c") || inner.IsConstant("c") {
+		t.Errorf("IsConstant should only report constants of its own scope")
+	}
+
+	// Shadowing in an enclosed scope gives an ordinary binding
+	inner.Set("c", &Integer{Value: 3})
+	if _, err := inner.Assign("c", &Integer{Value: 4}); err != nil {
+		t.Errorf("assigning a shadowing binding failed: %s", err)
+	}
+}
+
+func TestFreeze(t *testing.T) {
+	nested := &Array{Elements: []Object{&Integer{Value: 1}}}
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	key := &String{Value: "nested"}
+	hash.Pairs[key.HashKey()] = HashPair{Key: key, Value: nested}
+	array := &Array{Elements: []Object{hash}}
+
+	// Cycles must not make Freeze loop forever
+	nested.Elements = append(nested.Elements, array)
+
+	if Freeze(array) != array {
+		t.Fatalf("Freeze did not return its argument")
+	}
+
+	for _, value := range []Object{array, hash, nested} {
+		if !IsFrozen(value) {
+			t.Errorf("%s was not frozen", value.Type())
+		}
+	}
+
+	if IsFrozen(array.From(0)) {
+		t.Errorf("copies of frozen arrays must not be frozen")
+	}
+
+	if IsFrozen(&Integer{Value: 1}) {
+		t.Errorf("integers are not frozen")
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{1.5, "1.5"},
+		{2, "2.0"},
+		{-0.25, "-0.25"},
+		{1e21, "1e+21"},
+		{math.Inf(1), "+Inf"},
+	}
+
+	for _, tt := range tests {
+		inspected := (&Float{Value: tt.value}).Inspect()
+		if inspected != tt.expected {
+			t.Errorf("wrong inspection of %g. want=%q, got=%q", tt.value, tt.expected, inspected)
+		}
+	}
+}
+
+func TestFloatHashKey(t *testing.T) {
+	if (&Float{Value: 1.5}).HashKey() != (&Float{Value: 1.5}).HashKey() {
+		t.Errorf("floats with the same value have different hash keys")
+	}
+	if (&Float{Value: 0}).HashKey() != (&Float{Value: math.Copysign(0, -1)}).HashKey() {
+		t.Errorf("0.0 and -0.0 have different hash keys")
+	}
+	if (&Float{Value: 1}).HashKey() == (&Integer{Value: 1}).HashKey() {
+		t.Errorf("1.0 and 1 have the same hash key")
+	}
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	maxInt := &Integer{Value: math.MaxInt64}
+	minInt := &Integer{Value: math.MinInt64}
+
+	tests := []struct {
+		operator string
+		left     Object
+		right    Object
+		overflow IntegerOverflow
+		expected string
+	}{
+		{"+", &Integer{Value: 2}, &Integer{Value: 3}, OverflowError, "5"},
+		{"-", &Integer{Value: -7}, &Integer{Value: 3}, OverflowError, "-10"},
+		{"/", &Integer{Value: -7}, &Integer{Value: 2}, OverflowError, "-3"},
+		{"%", &Integer{Value: -7}, &Integer{Value: 2}, OverflowError, "-1"},
+		{"+", maxInt, &Integer{Value: 1}, OverflowError, "integer overflow: 9223372036854775807 + 1"},
+		{"-", minInt, &Integer{Value: 1}, OverflowError, "integer overflow: -9223372036854775808 - 1"},
+		{"*", maxInt, &Integer{Value: 2}, OverflowError, "integer overflow: 9223372036854775807 * 2"},
+		{"*", minInt, &Integer{Value: -1}, OverflowError, "integer overflow: -9223372036854775808 * -1"},
+		{"/", minInt, &Integer{Value: -1}, OverflowError, "integer overflow: -9223372036854775808 / -1"},
+		{"/", &Integer{Value: 1}, &Integer{Value: 0}, OverflowError, "division by zero"},
+		{"%", &Integer{Value: 1}, &Integer{Value: 0}, OverflowPromote, "division by zero"},
+		{"+", maxInt, &Integer{Value: 1}, OverflowPromote, "9223372036854775808"},
+		{"*", maxInt, maxInt, OverflowPromote, "85070591730234615847396907784232501249"},
+		{"/", minInt, &Integer{Value: -1}, OverflowPromote, "9223372036854775808"},
+	}
+
+	for _, tt := range tests {
+		result := IntegerArithmetic(tt.operator, tt.left, tt.right, tt.overflow)
+
+		var actual string
+		if err, ok := result.(*Error); ok {
+			actual = err.Message
+		} else {
+			actual = result.Inspect()
+		}
+
+		if actual != tt.expected {
+			t.Errorf("%s %s %s: want=%q, got=%q", tt.left.Inspect(), tt.operator, tt.right.Inspect(), tt.expected, actual)
+		}
+	}
+}
+
+func TestBigIntegerResultsAreNormalized(t *testing.T) {
+	big := IntegerArithmetic("+", &Integer{Value: math.MaxInt64}, &Integer{Value: 1}, OverflowPromote)
+	if _, ok := big.(*BigInteger); !ok {
+		t.Fatalf("result is not BigInteger. got=%T", big)
+	}
+
+	small := IntegerArithmetic("-", big, &Integer{Value: 1}, OverflowPromote)
+	integer, ok := small.(*Integer)
+	if !ok {
+		t.Fatalf("result is not Integer. got=%T", small)
+	}
+
+	if integer.Value != math.MaxInt64 {
+		t.Errorf("wrong value. want=%d, got=%d", int64(math.MaxInt64), integer.Value)
+	}
+
+	if CompareIntegers(big, small) != 1 || CompareIntegers(small, big) != -1 || CompareIntegers(big, big) != 0 {
+		t.Errorf("big integers compare wrongly")
+	}
+}
@@ -0,0 +1,17 @@
+package parser
+
+import (
+	"fmt"
+	"monkey-lang/token"
+)
+
+type ParseError struct {
+	Position token.Position
+	Expected []token.TokenType // the tokens that would have been accepted, empty when not applicable
+	Found    token.Token
+	Message  string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Position, e.Message)
+}
@@ -10,7 +10,14 @@ import (
 
 type Parser struct {
 	l      *lexer.Lexer
-	errors []string
+	errors []*ParseError
+
+	// Set after an error until the parser synchronizes at the next statement boundary,
+	// errors raised meanwhile are most likely caused by the first one and are dropped
+	panicking bool
+
+	loopDepth  int // the number of loops enclosing the current statement within the current function
+	braceDepth int // the number of braces opened up to and including the current token and not yet closed
 
 	currentToken token.Token
 	peekToken    token.Token
@@ -27,22 +34,28 @@ type (
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
 		l:      l,
-		errors: []string{},
+		errors: []*ParseError{},
 	}
 
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENTIFIER, p.parseIdentifier)
 	p.registerPrefix(token.INTEGER, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.PLUS, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
+	p.registerPrefix(token.NULL, p.parseNull)
 	p.registerPrefix(token.OPENPARENTHESIS, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.MATCH, p.parseMatchExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionExpression)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.STRINGHEAD, p.parseInterpolatedString)
 	p.registerPrefix(token.OPENBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.OPENBRACE, p.parseHashLiteral)
+	p.registerPrefix(token.ILLEGAL, p.parseIllegal)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
@@ -53,8 +66,19 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.NOTEQUAL, p.parseInfixExpression)
 	p.registerInfix(token.GREATERTHAN, p.parseInfixExpression)
 	p.registerInfix(token.LESSTHAN, p.parseInfixExpression)
+	p.registerInfix(token.GREATERTHANOREQUAL, p.parseInfixExpression)
+	p.registerInfix(token.LESSTHANOREQUAL, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.NULLCOALESCE, p.parseInfixExpression)
+	p.registerInfix(token.QUESTIONDOT, p.parseOptionalExpression)
+	p.registerInfix(token.QUESTIONBRACKET, p.parseOptionalExpression)
 	p.registerInfix(token.OPENPARENTHESIS, p.parseCallExpression)
 	p.registerInfix(token.OPENBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.ASSIGNMENT, p.parseAssignExpression)
+	p.registerInfix(token.PLUSASSIGNMENT, p.parseAssignExpression)
+	p.registerInfix(token.MINUSASSIGNMENT, p.parseAssignExpression)
 
 	// Set currentToken and peekToken by reading twice
 	p.nextToken()
@@ -66,6 +90,13 @@ func New(l *lexer.Lexer) *Parser {
 func (p *Parser) nextToken() {
 	p.currentToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+
+	switch p.currentToken.Type {
+	case token.OPENBRACE:
+		p.braceDepth++
+	case token.CLOSEBRACE:
+		p.braceDepth--
+	}
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -84,25 +115,77 @@ func (p *Parser) ParseProgram() *ast.Program {
 }
 
 func (p *Parser) parseStatement() ast.Statement {
+	errorCount := len(p.errors)
+
+	depth := p.braceDepth
+	if p.currentTokenIs(token.OPENBRACE) {
+		depth--
+	}
+
+	var statement ast.Statement
 	switch p.currentToken.Type {
-	case token.LET:
-		return p.parseLetStatement()
+	case token.LET, token.CONST:
+		statement = p.parseLetStatement()
 	case token.RETURN:
-		return p.parseReturnStatement()
+		statement = p.parseReturnStatement()
+	case token.WHILE:
+		statement = p.parseWhileStatement()
+	case token.FOR:
+		statement = p.parseForStatement()
+	case token.BREAK:
+		statement = p.parseBreakStatement()
+	case token.CONTINUE:
+		statement = p.parseContinueStatement()
 	default:
-		return p.parseExpressionStatement()
+		statement = p.parseExpressionStatement()
+	}
+
+	// A statement with errors is incomplete, drop it and skip ahead to the next one
+	if len(p.errors) > errorCount || p.panicking {
+		p.synchronize(depth)
+		return nil
 	}
+
+	return statement
+}
+
+// synchronize advances to the end of the current statement, which started at the given
+// brace depth: a semicolon, or the token before the next statement keyword, closing brace
+// or the end of input. Blocks the statement opened are skipped as a whole, so that their
+// statements and closing brace aren't mistaken for those of the enclosing block
+func (p *Parser) synchronize(depth int) {
+	for !p.currentTokenIs(token.EOF) {
+		if p.braceDepth <= depth {
+			if p.currentTokenIs(token.SEMICOLON) {
+				break
+			}
+			if p.peekTokenIs(token.LET) || p.peekTokenIs(token.CONST) || p.peekTokenIs(token.RETURN) ||
+				p.peekTokenIs(token.CLOSEBRACE) || p.peekTokenIs(token.EOF) {
+				break
+			}
+		}
+
+		p.nextToken()
+	}
+
+	p.panicking = false
 }
 
 func (p *Parser) parseLetStatement() *ast.LetStatement {
-	statement := &ast.LetStatement{Token: p.currentToken}
+	statement := &ast.LetStatement{Token: p.currentToken, Constant: p.currentTokenIs(token.CONST)}
 
-	if !p.expectPeek(token.IDENTIFIER) {
+	if p.peekTokenIs(token.OPENBRACKET) || p.peekTokenIs(token.OPENBRACE) {
+		p.nextToken()
+		statement.Pattern = p.parsePattern()
+		if statement.Pattern == nil || !p.checkPattern(statement.Pattern, false) {
+			return nil
+		}
+	} else if p.expectPeek(token.IDENTIFIER) {
+		statement.Name = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+	} else {
 		return nil
 	}
 
-	statement.Name = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
-
 	if !p.expectPeek(token.ASSIGNMENT) {
 		return nil
 	}
@@ -111,6 +194,10 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	statement.Value = p.parseExpression(LOWEST)
 
+	if function, ok := statement.Value.(*ast.FunctionExpression); ok && statement.Name != nil {
+		function.Name = statement.Name.Value
+	}
+
 	for p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -132,6 +219,115 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return statement
 }
 
+func (p *Parser) parseWhileStatement() *ast.WhileStatement {
+	statement := &ast.WhileStatement{Token: p.currentToken}
+
+	if !p.expectPeek(token.OPENPARENTHESIS) {
+		return nil
+	}
+
+	p.nextToken()
+	statement.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.CLOSEPARENTHESIS) {
+		return nil
+	}
+
+	if !p.expectPeek(token.OPENBRACE) {
+		return nil
+	}
+
+	p.loopDepth++
+	statement.Body = p.parseBlockStatement()
+	p.loopDepth--
+
+	for p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return statement
+}
+
+func (p *Parser) parseForStatement() *ast.ForStatement {
+	statement := &ast.ForStatement{Token: p.currentToken}
+
+	if !p.expectPeek(token.OPENPARENTHESIS) {
+		return nil
+	}
+
+	if !p.expectPeek(token.IDENTIFIER) {
+		return nil
+	}
+
+	statement.Value = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+
+	if p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+
+		if !p.expectPeek(token.IDENTIFIER) {
+			return nil
+		}
+
+		statement.Key = statement.Value
+		statement.Value = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+	}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+
+	p.nextToken()
+	statement.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.CLOSEPARENTHESIS) {
+		return nil
+	}
+
+	if !p.expectPeek(token.OPENBRACE) {
+		return nil
+	}
+
+	p.loopDepth++
+	statement.Body = p.parseBlockStatement()
+	p.loopDepth--
+
+	for p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return statement
+}
+
+func (p *Parser) parseBreakStatement() *ast.BreakStatement {
+	statement := &ast.BreakStatement{Token: p.currentToken}
+
+	if p.loopDepth == 0 {
+		p.addError(p.currentToken, nil, "break outside of loop")
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return statement
+}
+
+func (p *Parser) parseContinueStatement() *ast.ContinueStatement {
+	statement := &ast.ContinueStatement{Token: p.currentToken}
+
+	if p.loopDepth == 0 {
+		p.addError(p.currentToken, nil, "continue outside of loop")
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return statement
+}
+
 func (p *Parser) parseIdentifier() ast.Expression {
 	return &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
 }
@@ -142,7 +338,22 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	value, err := strconv.ParseInt(p.currentToken.Literal, 0, 64)
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as integer", p.currentToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(p.currentToken, nil, msg)
+		return nil
+	}
+
+	lit.Value = value
+
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.currentToken}
+
+	value, err := strconv.ParseFloat(p.currentToken.Literal, 64)
+	if err != nil {
+		msg := fmt.Sprintf("could not parse %q as float", p.currentToken.Literal)
+		p.addError(p.currentToken, nil, msg)
 		return nil
 	}
 
@@ -151,6 +362,10 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return lit
 }
 
+func (p *Parser) parseNull() ast.Expression {
+	return &ast.NullLiteral{Token: p.currentToken}
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.currentToken, Value: p.currentTokenIs(token.TRUE)}
 }
@@ -190,6 +405,21 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	if p.peekTokenIs(token.ELSE) {
 		p.nextToken()
 
+		// `else if` is short for an else block holding just the next if expression
+		if p.peekTokenIs(token.IF) {
+			p.nextToken()
+			ifToken := p.currentToken
+
+			alternative := p.parseIfExpression()
+			if alternative == nil {
+				return nil
+			}
+
+			statement := &ast.ExpressionStatement{Token: ifToken, Expression: alternative}
+			expression.Alternative = &ast.BlockStatement{Token: ifToken, Statements: []ast.Statement{statement}}
+			return expression
+		}
+
 		if !p.expectPeek(token.OPENBRACE) {
 			return nil
 		}
@@ -200,6 +430,274 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseMatchExpression() ast.Expression {
+	expression := &ast.MatchExpression{Token: p.currentToken}
+
+	if !p.expectPeek(token.OPENPARENTHESIS) {
+		return nil
+	}
+
+	p.nextToken()
+	expression.Subject = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.CLOSEPARENTHESIS) {
+		return nil
+	}
+
+	if !p.expectPeek(token.OPENBRACE) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.CLOSEBRACE) {
+		p.nextToken()
+
+		arm := p.parseMatchArm()
+		if arm == nil {
+			return nil
+		}
+		expression.Arms = append(expression.Arms, arm)
+
+		// Arms are separated by commas, which are optional after block bodies
+		if p.peekTokenIs(token.COMMA) {
+			p.nextToken()
+		} else if !p.currentTokenIs(token.CLOSEBRACE) && !p.peekTokenIs(token.CLOSEBRACE) {
+			p.peekError(token.COMMA)
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.CLOSEBRACE) {
+		return nil
+	}
+
+	return expression
+}
+
+func (p *Parser) parseMatchArm() *ast.MatchArm {
+	arm := &ast.MatchArm{Pattern: p.parsePattern()}
+	if arm.Pattern == nil || !p.checkPattern(arm.Pattern, true) {
+		return nil
+	}
+
+	if !p.expectPeek(token.ARROW) {
+		return nil
+	}
+
+	p.nextToken()
+
+	if p.currentTokenIs(token.OPENBRACE) {
+		arm.Body = p.parseBlockStatement()
+		return arm
+	}
+
+	statement := &ast.ExpressionStatement{Token: p.currentToken}
+	statement.Expression = p.parseExpression(LOWEST)
+	if statement.Expression == nil {
+		return nil
+	}
+
+	arm.Body = &ast.BlockStatement{Token: statement.Token, Statements: []ast.Statement{statement}}
+	return arm
+}
+
+// parsePattern parses the pattern starting at the current token, see ast.MatchArm
+func (p *Parser) parsePattern() ast.Expression {
+	switch p.currentToken.Type {
+	case token.IDENTIFIER:
+		return p.parseIdentifier()
+	case token.INTEGER, token.FLOAT, token.STRING, token.TRUE, token.FALSE, token.NULL:
+		return p.prefixParseFns[p.currentToken.Type]()
+	case token.MINUS:
+		if !p.peekTokenIs(token.INTEGER) && !p.peekTokenIs(token.FLOAT) {
+			break
+		}
+
+		expression := &ast.PrefixExpression{Token: p.currentToken, Operator: p.currentToken.Literal}
+		p.nextToken()
+		expression.Right = p.prefixParseFns[p.currentToken.Type]()
+		if expression.Right == nil {
+			return nil
+		}
+
+		return expression
+	case token.OPENBRACKET:
+		return p.parseArrayPattern()
+	case token.OPENBRACE:
+		return p.parseHashPattern()
+	}
+
+	p.addError(p.currentToken, nil, fmt.Sprintf("expected a pattern, got %s instead", p.currentToken.Type))
+	return nil
+}
+
+func (p *Parser) parseArrayPattern() ast.Expression {
+	pattern := &ast.ArrayPattern{Token: p.currentToken}
+
+	for !p.peekTokenIs(token.CLOSEBRACKET) {
+		p.nextToken()
+
+		if p.currentTokenIs(token.ELLIPSIS) {
+			pattern.Rest = p.parseRestElement()
+			if pattern.Rest == nil {
+				return nil
+			}
+			break
+		}
+
+		element := p.parseDefaultPattern(p.parsePattern())
+		if element == nil {
+			return nil
+		}
+		pattern.Elements = append(pattern.Elements, element)
+
+		if !p.peekTokenIs(token.CLOSEBRACKET) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.CLOSEBRACKET) {
+		return nil
+	}
+
+	return pattern
+}
+
+func (p *Parser) parseHashPattern() ast.Expression {
+	pattern := &ast.HashPattern{Token: p.currentToken}
+
+	for !p.peekTokenIs(token.CLOSEBRACE) {
+		p.nextToken()
+
+		if p.currentTokenIs(token.ELLIPSIS) {
+			pattern.Rest = p.parseRestElement()
+			if pattern.Rest == nil {
+				return nil
+			}
+			break
+		}
+
+		// `{name}` is short for `{"name": name}`
+		if p.currentTokenIs(token.IDENTIFIER) && !p.peekTokenIs(token.COLON) {
+			key := &ast.StringLiteral{Token: p.currentToken, Value: p.currentToken.Literal}
+			value := p.parseDefaultPattern(p.parseIdentifier())
+			if value == nil {
+				return nil
+			}
+
+			pattern.Keys = append(pattern.Keys, key)
+			pattern.Values = append(pattern.Values, value)
+
+			if !p.peekTokenIs(token.CLOSEBRACE) && !p.expectPeek(token.COMMA) {
+				return nil
+			}
+			continue
+		}
+
+		key := p.parsePattern()
+		if key == nil {
+			return nil
+		}
+
+		switch key.(type) {
+		case *ast.StringLiteral, *ast.IntegerLiteral, *ast.Boolean:
+		default:
+			p.addError(p.currentToken, nil, fmt.Sprintf("hash pattern keys must be string, integer or boolean literals, got %s", key.String()))
+			return nil
+		}
+
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+
+		p.nextToken()
+
+		value := p.parseDefaultPattern(p.parsePattern())
+		if value == nil {
+			return nil
+		}
+
+		pattern.Keys = append(pattern.Keys, key)
+		pattern.Values = append(pattern.Values, value)
+
+		if !p.peekTokenIs(token.CLOSEBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.CLOSEBRACE) {
+		return nil
+	}
+
+	return pattern
+}
+
+// parseRestElement parses the `...name` ending an array or hash pattern
+func (p *Parser) parseRestElement() *ast.Identifier {
+	if !p.expectPeek(token.IDENTIFIER) {
+		return nil
+	}
+
+	return &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+}
+
+// parseDefaultPattern parses the `= default` that may follow an element of a pattern
+func (p *Parser) parseDefaultPattern(target ast.Expression) ast.Expression {
+	if target == nil || !p.peekTokenIs(token.ASSIGNMENT) {
+		return target
+	}
+
+	p.nextToken()
+	pattern := &ast.DefaultPattern{Token: p.currentToken, Target: target}
+
+	p.nextToken()
+	pattern.Default = p.parseExpression(LOWEST)
+	if pattern.Default == nil {
+		return nil
+	}
+
+	return pattern
+}
+
+// checkPattern reports an error for the parts of pattern only one of match
+// and let supports: literals, which a let can't bind, and defaults, which
+// a match has no use for
+func (p *Parser) checkPattern(pattern ast.Expression, inMatch bool) bool {
+	var invalid ast.Expression
+	var msg string
+
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		return true
+	case *ast.ArrayPattern:
+		for _, element := range pattern.Elements {
+			if !p.checkPattern(element, inMatch) {
+				return false
+			}
+		}
+		return true
+	case *ast.HashPattern:
+		for _, value := range pattern.Values {
+			if !p.checkPattern(value, inMatch) {
+				return false
+			}
+		}
+		return true
+	case *ast.DefaultPattern:
+		if !inMatch {
+			return p.checkPattern(pattern.Target, inMatch)
+		}
+		invalid, msg = pattern, "match patterns can't have defaults"
+	default:
+		if inMatch {
+			return true
+		}
+		invalid, msg = pattern, fmt.Sprintf("cannot bind to %s", pattern.String())
+	}
+
+	p.addError(token.Token{Literal: invalid.TokenLiteral(), Position: invalid.Position()}, nil, msg)
+	return false
+}
+
 func (p *Parser) parseFunctionExpression() ast.Expression {
 	expression := &ast.FunctionExpression{Token: p.currentToken}
 
@@ -207,24 +705,87 @@ func (p *Parser) parseFunctionExpression() ast.Expression {
 		return nil
 	}
 
-	expression.Parameters = p.parseFunctionParameters()
+	if !p.parseFunctionParameters(expression) {
+		return nil
+	}
 
 	if !p.expectPeek(token.OPENBRACE) {
 		return nil
 	}
 
+	// Loops around the function do not extend into its body
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
 	expression.Body = p.parseBlockStatement()
+	p.loopDepth = loopDepth
 
 	return expression
 }
 
+// parseIllegal reports a token the lexer couldn't make sense of, using the
+// lexer's explanation when it has one
+func (p *Parser) parseIllegal() ast.Expression {
+	msg := fmt.Sprintf("illegal character %q", p.currentToken.Literal)
+	if err, ok := p.l.ErrorAt(p.currentToken.Position); ok {
+		msg = err.Message
+	}
+
+	p.addError(p.currentToken, nil, msg)
+	return nil
+}
+
 func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.currentToken, Value: p.currentToken.Literal}
 }
 
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	interpolated := &ast.InterpolatedString{Token: p.currentToken}
+	interpolated.Parts = p.appendStringPart(interpolated.Parts)
+
+	for !p.currentTokenIs(token.STRINGTAIL) {
+		if p.peekTokenIs(token.STRINGMIDDLE) || p.peekTokenIs(token.STRINGTAIL) {
+			p.addError(p.peekToken, nil, "empty interpolation")
+			return nil
+		}
+
+		p.nextToken()
+		part := p.parseExpression(LOWEST)
+		if part == nil {
+			return nil
+		}
+		interpolated.Parts = append(interpolated.Parts, part)
+
+		if p.peekTokenIs(token.ILLEGAL) {
+			// most likely the rest of the string is malformed
+			p.nextToken()
+			return p.parseIllegal()
+		}
+
+		if !p.peekTokenIs(token.STRINGMIDDLE) && !p.peekTokenIs(token.STRINGTAIL) {
+			msg := fmt.Sprintf("expected } to close the interpolation, got %s instead", p.peekToken.Type)
+			p.addError(p.peekToken, []token.TokenType{token.CLOSEBRACE}, msg)
+			return nil
+		}
+
+		p.nextToken()
+		interpolated.Parts = p.appendStringPart(interpolated.Parts)
+	}
+
+	return interpolated
+}
+
+// appendStringPart adds the text of the current string piece to parts, unless it is empty
+func (p *Parser) appendStringPart(parts []ast.Expression) []ast.Expression {
+	if p.currentToken.Literal == "" {
+		return parts
+	}
+
+	return append(parts, &ast.StringLiteral{Token: p.currentToken, Value: p.currentToken.Literal})
+}
+
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.currentToken}
-	array.Elements = p.parseExpressionList(token.CLOSEBRACKET)
+	array.Elements = p.parseExpressionList(token.CLOSEBRACKET, p.parseListElement)
 
 	return array
 }
@@ -258,30 +819,50 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	return hash
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
-
-	p.nextToken()
-
-	if p.currentTokenIs(token.CLOSEPARENTHESIS) {
-		return identifiers
-	}
+// parseFunctionParameters parses the parameters of a function up to the
+// closing parenthesis: identifiers, optionally followed by `= default`, and
+// a final `...rest`
+func (p *Parser) parseFunctionParameters(function *ast.FunctionExpression) bool {
+	function.Parameters = []*ast.Identifier{}
+
+	for !p.peekTokenIs(token.CLOSEPARENTHESIS) {
+		if p.peekTokenIs(token.ELLIPSIS) {
+			p.nextToken()
+			function.Rest = p.parseRestElement()
+			if function.Rest == nil {
+				return false
+			}
+			break
+		}
 
-	identifier := &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
-	identifiers = append(identifiers, identifier)
+		if !p.expectPeek(token.IDENTIFIER) {
+			return false
+		}
+		parameter := &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+
+		var defaultValue ast.Expression
+		if p.peekTokenIs(token.ASSIGNMENT) {
+			p.nextToken()
+			p.nextToken()
+			defaultValue = p.parseExpression(LOWEST)
+			if defaultValue == nil {
+				return false
+			}
+		} else if function.NumRequired() < len(function.Defaults) {
+			msg := fmt.Sprintf("required parameter %s can't follow parameters with defaults", parameter.Value)
+			p.addError(p.currentToken, nil, msg)
+			return false
+		}
 
-	for p.peekTokenIs(token.COMMA) {
-		p.nextToken()
-		p.nextToken()
-		identifier := &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
-		identifiers = append(identifiers, identifier)
-	}
+		function.Parameters = append(function.Parameters, parameter)
+		function.Defaults = append(function.Defaults, defaultValue)
 
-	if !p.expectPeek(token.CLOSEPARENTHESIS) {
-		return nil
+		if !p.peekTokenIs(token.CLOSEPARENTHESIS) && !p.expectPeek(token.COMMA) {
+			return false
+		}
 	}
 
-	return identifiers
+	return p.expectPeek(token.CLOSEPARENTHESIS)
 }
 
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
@@ -330,10 +911,52 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	expression := &ast.CallExpression{Token: p.currentToken, Function: function}
-	expression.Arguments = p.parseExpressionList(token.CLOSEPARENTHESIS)
+	expression.Arguments = p.parseExpressionList(token.CLOSEPARENTHESIS, p.parseCallArgument)
+	if expression.Arguments == nil || !p.checkCallArguments(expression.Arguments) {
+		return nil
+	}
+
 	return expression
 }
 
+// checkCallArguments reports named arguments followed by positional ones,
+// named arguments mixed with spread ones, and names given more than once
+func (p *Parser) checkCallArguments(arguments []ast.Expression) bool {
+	names := map[string]bool{}
+	hasSpread := false
+
+	for _, argument := range arguments {
+		var msg string
+		switch argument := argument.(type) {
+		case nil:
+			return false
+		case *ast.NamedArgument:
+			if names[argument.Name.Value] {
+				msg = fmt.Sprintf("duplicate named argument %s", argument.Name.Value)
+			} else if hasSpread {
+				msg = "named arguments can't be combined with spread arguments"
+			}
+			names[argument.Name.Value] = true
+		case *ast.SpreadExpression:
+			if len(names) > 0 {
+				msg = "named arguments can't be combined with spread arguments"
+			}
+			hasSpread = true
+		default:
+			if len(names) > 0 {
+				msg = "positional arguments can't follow named arguments"
+			}
+		}
+
+		if msg != "" {
+			p.addError(token.Token{Literal: argument.TokenLiteral(), Position: argument.Position()}, nil, msg)
+			return false
+		}
+	}
+
+	return true
+}
+
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	expression := &ast.IndexExpression{Token: p.currentToken, Left: left}
 
@@ -347,6 +970,62 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+// parseOptionalExpression parses the safe navigation forms `a?[i]`, `a?.[i]`
+// and `f?.(x)`, which give null when the indexed value or function is null
+func (p *Parser) parseOptionalExpression(left ast.Expression) ast.Expression {
+	operator := p.currentToken
+
+	if operator.Type == token.QUESTIONDOT {
+		switch {
+		case p.peekTokenIs(token.OPENPARENTHESIS):
+			p.nextToken()
+			call, ok := p.parseCallExpression(left).(*ast.CallExpression)
+			if !ok {
+				return nil
+			}
+			call.Token = operator
+			call.Optional = true
+			return call
+		case p.peekTokenIs(token.OPENBRACKET):
+			p.nextToken()
+		default:
+			msg := fmt.Sprintf("expected ( or [ after ?., got %s instead", p.peekToken.Type)
+			p.addError(p.peekToken, []token.TokenType{token.OPENPARENTHESIS, token.OPENBRACKET}, msg)
+			return nil
+		}
+	}
+
+	index, ok := p.parseIndexExpression(left).(*ast.IndexExpression)
+	if !ok {
+		return nil
+	}
+	index.Token = operator
+	index.Optional = true
+
+	return index
+}
+
+func (p *Parser) parseAssignExpression(left ast.Expression) ast.Expression {
+	operator := p.currentToken
+
+	// Assignments are right-associative, `a = b = 1` assigns 1 to b and then to a
+	p.nextToken()
+	value := p.parseExpression(LOWEST)
+
+	switch left := left.(type) {
+	case *ast.Identifier:
+		return &ast.AssignExpression{Token: operator, Name: left, Operator: operator.Literal, Value: value}
+	case *ast.IndexExpression:
+		if left.Optional {
+			break
+		}
+		return &ast.IndexAssignExpression{Token: operator, Left: left.Left, Index: left.Index, Operator: operator.Literal, Value: value}
+	}
+
+	p.addError(operator, nil, fmt.Sprintf("cannot assign to %s", left.String()))
+	return nil
+}
+
 func (p *Parser) currentTokenIs(t token.TokenType) bool {
 	return p.currentToken.Type == t
 }
@@ -365,13 +1044,31 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 	return false
 }
 
-func (p *Parser) Errors() []string {
+// Errors returns the errors found while parsing, in source order and without duplicates
+func (p *Parser) Errors() []*ParseError {
 	return p.errors
 }
 
 func (p *Parser) peekError(t token.TokenType) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.addError(p.peekToken, []token.TokenType{t}, msg)
+}
+
+func (p *Parser) addError(found token.Token, expected []token.TokenType, msg string) {
+	if p.panicking {
+		return
+	}
+	p.panicking = true
+
+	err := &ParseError{Position: found.Position, Expected: expected, Found: found, Message: msg}
+
+	for _, existing := range p.errors {
+		if existing.Position == err.Position && existing.Message == err.Message {
+			return
+		}
+	}
+
+	p.errors = append(p.errors, err)
 }
 
 func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn) {
@@ -415,7 +1112,9 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	return leftExp
 }
 
-func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+// parseExpressionList parses comma separated elements with parseElement up
+// to the end token
+func (p *Parser) parseExpressionList(end token.TokenType, parseElement func() ast.Expression) []ast.Expression {
 	list := []ast.Expression{}
 
 	if p.peekTokenIs(end) {
@@ -424,12 +1123,12 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	}
 
 	p.nextToken()
-	list = append(list, p.parseExpression(LOWEST))
+	list = append(list, parseElement())
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		list = append(list, p.parseExpression(LOWEST))
+		list = append(list, parseElement())
 	}
 
 	if !p.expectPeek(end) {
@@ -439,9 +1138,51 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	return list
 }
 
+func (p *Parser) parseListElement() ast.Expression {
+	return p.parseExpression(LOWEST)
+}
+
+// parseCallArgument parses an argument of a call, which may spread an array
+// into several arguments with `...args`
+func (p *Parser) parseCallArgument() ast.Expression {
+	if p.currentTokenIs(token.IDENTIFIER) && p.peekTokenIs(token.COLON) {
+		return p.parseNamedArgument()
+	}
+
+	if !p.currentTokenIs(token.ELLIPSIS) {
+		return p.parseExpression(LOWEST)
+	}
+
+	spread := &ast.SpreadExpression{Token: p.currentToken}
+
+	p.nextToken()
+	spread.Value = p.parseExpression(LOWEST)
+	if spread.Value == nil {
+		return nil
+	}
+
+	return spread
+}
+
+// parseNamedArgument parses an argument passed by the name of its parameter
+// like `y: 3`
+func (p *Parser) parseNamedArgument() ast.Expression {
+	argument := &ast.NamedArgument{Token: p.currentToken}
+	argument.Name = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+
+	p.nextToken()
+	p.nextToken()
+	argument.Value = p.parseExpression(LOWEST)
+	if argument.Value == nil {
+		return nil
+	}
+
+	return argument
+}
+
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
 	msg := fmt.Sprintf("No prefix parse function was found for '%s'.", t)
-	p.errors = append(p.errors, msg)
+	p.addError(p.currentToken, nil, msg)
 }
 
 func (p *Parser) peekPrecedence() int {
@@ -463,24 +1204,39 @@ func (p *Parser) currentPrecedence() int {
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN      // = or += or -=
+	COALESCE    // ??
+	LOGICALOR   // ||
+	LOGICALAND  // &&
 	EQUALS      // ==
-	LESSGREATER // > or <
+	LESSGREATER // >, <, >= or <=
 	SUM         // +
-	PRODUCT     // *
-	PREFIX      //-X or !X
+	PRODUCT     // * or / or %
+	PREFIX      //-X, +X or !X
 	CALL        // myFuync(X)
 	INDEX       // array[index]
 )
 
 var precedences = map[token.TokenType]int{
-	token.EQUAL:           EQUALS,
-	token.NOTEQUAL:        EQUALS,
-	token.LESSTHAN:        LESSGREATER,
-	token.GREATERTHAN:     LESSGREATER,
-	token.PLUS:            SUM,
-	token.MINUS:           SUM,
-	token.SLASH:           PRODUCT,
-	token.ASTERISK:        PRODUCT,
-	token.OPENPARENTHESIS: CALL,
-	token.OPENBRACKET:     INDEX,
+	token.NULLCOALESCE:       COALESCE,
+	token.OR:                 LOGICALOR,
+	token.AND:                LOGICALAND,
+	token.EQUAL:              EQUALS,
+	token.NOTEQUAL:           EQUALS,
+	token.LESSTHAN:           LESSGREATER,
+	token.GREATERTHAN:        LESSGREATER,
+	token.LESSTHANOREQUAL:    LESSGREATER,
+	token.GREATERTHANOREQUAL: LESSGREATER,
+	token.PLUS:               SUM,
+	token.MINUS:              SUM,
+	token.SLASH:              PRODUCT,
+	token.ASTERISK:           PRODUCT,
+	token.PERCENT:            PRODUCT,
+	token.OPENPARENTHESIS:    CALL,
+	token.OPENBRACKET:        INDEX,
+	token.QUESTIONDOT:        INDEX,
+	token.QUESTIONBRACKET:    INDEX,
+	token.ASSIGNMENT:         ASSIGN,
+	token.PLUSASSIGNMENT:     ASSIGN,
+	token.MINUSASSIGNMENT:    ASSIGN,
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"monkey-lang/ast"
 	"monkey-lang/lexer"
+	"monkey-lang/token"
 	"strconv"
 	"testing"
 )
@@ -42,6 +43,110 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestDestructuringLetStatements(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedPattern string
+		expectedString  string
+	}{
+		{"let [a, b] = x;", "[a, b]", "let [a, b] = x;"},
+		{"let [a, _, b = 2] = x", "[a, _, b = 2]", "let [a, _, b = 2] = x;"},
+		{"let [first, ...rest] = x", "[first, ...rest]", "let [first, ...rest] = x;"},
+		{"let [[a], {b}] = x", "[[a], {b: b}]", "let [[a], {b: b}] = x;"},
+		{`let {"k": v, 1: one} = x`, "{k: v, 1: one}", "let {k: v, 1: one} = x;"},
+		{"let {name, age = 3, ...others} = x", "{name: name, age: age = 3, ...others}", "let {name: name, age: age = 3, ...others} = x;"},
+	}
+
+	for _, tt := range tests {
+		lexer := lexer.New(tt.input)
+		parser := New(lexer)
+		program := parser.ParseProgram()
+		checkParserErrors(t, parser)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+
+		statement, ok := program.Statements[0].(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("statement is not *ast.LetStatement. got=%T", program.Statements[0])
+		}
+
+		if statement.Name != nil {
+			t.Errorf("statement.Name is not nil. got=%s", statement.Name)
+		}
+
+		if statement.Pattern.String() != tt.expectedPattern {
+			t.Errorf("wrong pattern. expected=%q, got=%q", tt.expectedPattern, statement.Pattern.String())
+		}
+
+		if statement.String() != tt.expectedString {
+			t.Errorf("wrong string. expected=%q, got=%q", tt.expectedString, statement.String())
+		}
+	}
+}
+
+func TestDestructuringLetStatementErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"let [a, 1] = x", "1:9: cannot bind to 1"},
+		{`let {"k": "v"} = x`, "1:11: cannot bind to v"},
+		{"let [a, ...] = x", "1:12: expected next token to be IDENTIFIER, got ] instead"},
+		{"let [...a, b] = x", "1:10: expected next token to be ], got , instead"},
+		{"match (x) { [a = 1] => a }", "1:16: match patterns can't have defaults"},
+	}
+
+	for _, tt := range tests {
+		lexer := lexer.New(tt.input)
+		parser := New(lexer)
+		parser.ParseProgram()
+
+		errors := parser.Errors()
+		if len(errors) == 0 {
+			t.Errorf("%q: expected a parser error", tt.input)
+			continue
+		}
+
+		if errors[0].Error() != tt.expectedError {
+			t.Errorf("%q: wrong error. expected=%q, got=%q", tt.input, tt.expectedError, errors[0].Error())
+		}
+	}
+}
+
+func TestConstStatements(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedConstant bool
+		expectedString   string
+	}{
+		{"const x = 5;", true, "const x = 5;"},
+		{"const [a, ...b] = y", true, "const [a, ...b] = y;"},
+		{"let x = 5;", false, "let x = 5;"},
+	}
+
+	for _, tt := range tests {
+		lexer := lexer.New(tt.input)
+		parser := New(lexer)
+		program := parser.ParseProgram()
+		checkParserErrors(t, parser)
+
+		statement, ok := program.Statements[0].(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("statement is not *ast.LetStatement. got=%T", program.Statements[0])
+		}
+
+		if statement.Constant != tt.expectedConstant {
+			t.Errorf("statement.Constant wrong. expected=%t, got=%t", tt.expectedConstant, statement.Constant)
+		}
+
+		if statement.String() != tt.expectedString {
+			t.Errorf("wrong string. expected=%q, got=%q", tt.expectedString, statement.String())
+		}
+	}
+}
+
 func TestReturnStatements(t *testing.T) {
 	tests := []struct {
 		input         string
@@ -116,6 +221,107 @@ func checkParserErrors(t *testing.T, p *Parser) {
 	t.FailNow()
 }
 
+func TestParserErrors(t *testing.T) {
+	input := `let x = 5;
+let = 10;`
+
+	lexer := lexer.New(input)
+	parser := New(lexer)
+	parser.ParseProgram()
+
+	errors := parser.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 parser error, got=%d (%v)", len(errors), errors)
+	}
+
+	err := errors[0]
+	if err.Error() != "2:5: expected next token to be IDENTIFIER, got = instead" {
+		t.Errorf("wrong error. got=%q", err.Error())
+	}
+
+	expectedPosition := token.Position{Line: 2, Column: 5, Offset: 15}
+	if err.Position != expectedPosition {
+		t.Errorf("wrong position. expected=%+v, got=%+v", expectedPosition, err.Position)
+	}
+
+	if len(err.Expected) != 1 || err.Expected[0] != token.IDENTIFIER {
+		t.Errorf("wrong expected tokens. got=%v", err.Expected)
+	}
+
+	if err.Found.Type != token.ASSIGNMENT {
+		t.Errorf("wrong found token. expected=%s, got=%s", token.ASSIGNMENT, err.Found.Type)
+	}
+}
+
+func TestParserErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedErrors     []string
+		expectedStatements int
+	}{
+		{
+			"let = 10; let y = 5; y;",
+			[]string{"1:5: expected next token to be IDENTIFIER, got = instead"},
+			2,
+		},
+		{
+			"let x 5\nlet y = 2;\nlet z = ;",
+			[]string{
+				"1:7: expected next token to be =, got INT instead",
+				"3:9: No prefix parse function was found for ';'.",
+			},
+			1,
+		},
+		{
+			"let f = fn() { let = 1; 2 }; f();",
+			[]string{"1:20: expected next token to be IDENTIFIER, got = instead"},
+			1,
+		},
+		{
+			"add(1, , , 2); 3",
+			[]string{"1:8: No prefix parse function was found for ','."},
+			1,
+		},
+		{
+			"let f = fn(x) { if (x > 1 { return 1 } }; f(2);",
+			[]string{"1:27: expected next token to be ), got { instead"},
+			1,
+		},
+		{
+			"let {k: 1} = h; k",
+			[]string{"1:6: hash pattern keys must be string, integer or boolean literals, got k"},
+			1,
+		},
+		{
+			"let f = fn() { let = 1; let y = 2 }; puts(1)",
+			[]string{"1:20: expected next token to be IDENTIFIER, got = instead"},
+			1,
+		},
+	}
+
+	for _, tt := range tests {
+		lexer := lexer.New(tt.input)
+		parser := New(lexer)
+		program := parser.ParseProgram()
+
+		errors := parser.Errors()
+		if len(errors) != len(tt.expectedErrors) {
+			t.Errorf("%q: wrong number of errors. expected=%d, got=%d (%v)", tt.input, len(tt.expectedErrors), len(errors), errors)
+			continue
+		}
+
+		for i, expected := range tt.expectedErrors {
+			if errors[i].Error() != expected {
+				t.Errorf("%q: wrong error %d. expected=%q, got=%q", tt.input, i, expected, errors[i].Error())
+			}
+		}
+
+		if len(program.Statements) != tt.expectedStatements {
+			t.Errorf("%q: wrong number of statements. expected=%d, got=%d", tt.input, tt.expectedStatements, len(program.Statements))
+		}
+	}
+}
+
 func TestIdentifierExpression(t *testing.T) {
 	input := "foobar"
 
@@ -149,6 +355,34 @@ func TestIdentifierExpression(t *testing.T) {
 	}
 }
 
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"1.5;", 1.5},
+		{"2e3;", 2000},
+		{"2.5e-1;", 0.25},
+	}
+
+	for _, tt := range tests {
+		lexer := lexer.New(tt.input)
+		parser := New(lexer)
+		program := parser.ParseProgram()
+		checkParserErrors(t, parser)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("Expression not *ast.FloatLiteral. got=%T", stmt.Expression)
+		}
+
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %g, got=%g", tt.expected, literal.Value)
+		}
+	}
+}
+
 func TestIntegerLiteralExpression(t *testing.T) {
 	input := "5;"
 
@@ -363,6 +597,48 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		}, {
 			"add(a * b[2], b[1], 2*[1,2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
+		}, {
+			"a % b * c",
+			"((a % b) * c)",
+		}, {
+			"a + b % c <= d",
+			"((a + (b % c)) <= d)",
+		}, {
+			"a >= b == c <= d",
+			"((a >= b) == (c <= d))",
+		}, {
+			"+a * -b",
+			"((+a) * (-b))",
+		}, {
+			"a || b && c",
+			"(a || (b && c))",
+		}, {
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		}, {
+			"a < b && !c == d",
+			"((a < b) && ((!c) == d))",
+		}, {
+			"x = a || b",
+			"(x = (a || b))",
+		}, {
+			"a = b = c + 1",
+			"(a = (b = (c + 1)))",
+		}, {
+			"a += b * 2",
+			"(a += (b * 2))",
+		}, {
+			"a[i + 1] -= f(x)[0]",
+			"(a[(i + 1)] -= (f(x)[0]))",
+		}, {
+			"a ?? b || c ?? d",
+			"((a ?? (b || c)) ?? d)",
+		}, {
+			"x = a ?? null",
+			"(x = (a ?? null))",
+		}, {
+			"-a?[0]?.[1] + f?.(x)(y)",
+			"((-((a?[0])?[1])) + f?.(x)(y))",
 		},
 	}
 
@@ -519,6 +795,385 @@ func TestIfElseExpression(t *testing.T) {
 	}
 }
 
+func TestElseIfExpression(t *testing.T) {
+	input := `if (x < 0) { a } else if (x == 0) { b } else if (x < 10) { c } else { d }`
+
+	lexer := lexer.New(input)
+	parser := New(lexer)
+	program := parser.ParseProgram()
+	checkParserErrors(t, parser)
+
+	statement := program.Statements[0].(*ast.ExpressionStatement)
+	expression, ok := statement.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("statement.Expression is not ast.IfExpression. got=%T", statement.Expression)
+	}
+
+	for _, condition := range []string{"(x < 0)", "(x == 0)", "(x < 10)"} {
+		if expression.Condition.String() != condition {
+			t.Fatalf("wrong condition. expected=%s, got=%s", condition, expression.Condition)
+		}
+
+		if expression.Alternative == nil || len(expression.Alternative.Statements) != 1 {
+			t.Fatalf("expected an alternative with a single statement. got=%v", expression.Alternative)
+		}
+
+		alternative := expression.Alternative.Statements[0].(*ast.ExpressionStatement)
+		next, ok := alternative.Expression.(*ast.IfExpression)
+		if !ok {
+			testIdentifier(t, alternative.Expression, "d")
+			break
+		}
+		expression = next
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	input := `match (f(x)) {
+		1 => "one",
+		-2.5 => { let y = 2; y }
+		[a, _, [null]] => a,
+		{"k": true, 2: v} => v,
+		_ => 0,
+	}`
+
+	lexer := lexer.New(input)
+	parser := New(lexer)
+	program := parser.ParseProgram()
+	checkParserErrors(t, parser)
+
+	statement := program.Statements[0].(*ast.ExpressionStatement)
+	expression, ok := statement.Expression.(*ast.MatchExpression)
+	if !ok {
+		t.Fatalf("statement.Expression is not ast.MatchExpression. got=%T", statement.Expression)
+	}
+
+	if expression.Subject.String() != "f(x)" {
+		t.Errorf("wrong subject. got=%s", expression.Subject)
+	}
+
+	tests := []struct {
+		pattern string
+		body    string
+	}{
+		{"1", "one"},
+		{"(-2.5)", "let y = 2;y"},
+		{"[a, _, [null]]", "a"},
+		{"{k: true, 2: v}", "v"},
+		{"_", "0"},
+	}
+
+	if len(expression.Arms) != len(tests) {
+		t.Fatalf("wrong number of arms. expected=%d, got=%d", len(tests), len(expression.Arms))
+	}
+
+	for i, tt := range tests {
+		arm := expression.Arms[i]
+
+		if arm.Pattern.String() != tt.pattern {
+			t.Errorf("arms[%d] - wrong pattern. expected=%s, got=%s", i, tt.pattern, arm.Pattern)
+		}
+
+		if arm.Body.String() != tt.body {
+			t.Errorf("arms[%d] - wrong body. expected=%s, got=%s", i, tt.body, arm.Body)
+		}
+	}
+
+	if _, ok := expression.Arms[2].Pattern.(*ast.ArrayPattern); !ok {
+		t.Errorf("arms[2] - pattern is not ast.ArrayPattern. got=%T", expression.Arms[2].Pattern)
+	}
+
+	if _, ok := expression.Arms[3].Pattern.(*ast.HashPattern); !ok {
+		t.Errorf("arms[3] - pattern is not ast.HashPattern. got=%T", expression.Arms[3].Pattern)
+	}
+}
+
+func TestMatchExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"match (x) { 1 => 2 3 => 4 }", "1:20: expected next token to be ,, got INT instead"},
+		{"match (x) { 1 + 2 => 3 }", "1:15: expected next token to be =>, got + instead"},
+		{"match (x) { f(y) => 3 }", "1:14: expected next token to be =>, got ( instead"},
+		{"match (x) { -a => 3 }", "1:13: expected a pattern, got - instead"},
+		{"match (x) { {k: 1} => 3 }", "1:14: hash pattern keys must be string, integer or boolean literals, got k"},
+		{"match x { _ => 3 }", "1:7: expected next token to be (, got IDENTIFIER instead"},
+	}
+
+	for _, tt := range tests {
+		lexer := lexer.New(tt.input)
+		parser := New(lexer)
+		parser.ParseProgram()
+
+		errors := parser.Errors()
+		if len(errors) != 1 {
+			t.Errorf("%q: expected exactly one parser error, got=%d (%v)", tt.input, len(errors), errors)
+			continue
+		}
+
+		if errors[0].Error() != tt.expectedError {
+			t.Errorf("%q: wrong error. expected=%q, got=%q", tt.input, tt.expectedError, errors[0].Error())
+		}
+	}
+}
+
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < y) { x; break; continue; }`
+
+	lexer := lexer.New(input)
+	parser := New(lexer)
+	program := parser.ParseProgram()
+	checkParserErrors(t, parser)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n", 1, len(program.Statements))
+	}
+
+	statement, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.WhileStatement. got=%T", program.Statements[0])
+	}
+
+	if !testInfixExpression(t, statement.Condition, "x", "<", "y") {
+		return
+	}
+
+	if len(statement.Body.Statements) != 3 {
+		t.Fatalf("body is not 3 statements. got=%d\n", len(statement.Body.Statements))
+	}
+
+	if _, ok := statement.Body.Statements[1].(*ast.BreakStatement); !ok {
+		t.Errorf("body.Statements[1] is not ast.BreakStatement. got=%T", statement.Body.Statements[1])
+	}
+
+	if _, ok := statement.Body.Statements[2].(*ast.ContinueStatement); !ok {
+		t.Errorf("body.Statements[2] is not ast.ContinueStatement. got=%T", statement.Body.Statements[2])
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedKey      string
+		expectedValue    string
+		expectedIterable string
+	}{
+		{"for (x in arr) { x; break; }", "", "x", "arr"},
+		{"for (k, v in {1: 2}) { continue; }", "k", "v", "{1:2}"},
+	}
+
+	for _, tt := range tests {
+		lexer := lexer.New(tt.input)
+		parser := New(lexer)
+		program := parser.ParseProgram()
+		checkParserErrors(t, parser)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain %d statements. got=%d\n", 1, len(program.Statements))
+		}
+
+		statement, ok := program.Statements[0].(*ast.ForStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ForStatement. got=%T", program.Statements[0])
+		}
+
+		if tt.expectedKey == "" {
+			if statement.Key != nil {
+				t.Errorf("statement.Key is not nil. got=%q", statement.Key.String())
+			}
+		} else if !testIdentifier(t, statement.Key, tt.expectedKey) {
+			return
+		}
+
+		if !testIdentifier(t, statement.Value, tt.expectedValue) {
+			return
+		}
+
+		if statement.Iterable.String() != tt.expectedIterable {
+			t.Errorf("statement.Iterable wrong. expected=%q, got=%q", tt.expectedIterable, statement.Iterable.String())
+		}
+	}
+}
+
+func TestLoopStatementsWithTrailingSemicolons(t *testing.T) {
+	tests := []string{
+		"while (false) {}; x",
+		"for (x in y) {};; x",
+	}
+
+	for _, input := range tests {
+		lexer := lexer.New(input)
+		parser := New(lexer)
+		program := parser.ParseProgram()
+		checkParserErrors(t, parser)
+
+		if len(program.Statements) != 2 {
+			t.Fatalf("%q: program.Statements does not contain %d statements. got=%d\n", input, 2, len(program.Statements))
+		}
+
+		statement, ok := program.Statements[1].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("%q: program.Statements[1] is not ast.ExpressionStatement. got=%T", input, program.Statements[1])
+		}
+
+		testIdentifier(t, statement.Expression, "x")
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedOperator string
+		expectedValue    interface{}
+	}{
+		{"x = 5;", "=", 5},
+		{"x += y;", "+=", "y"},
+		{"x -= true;", "-=", true},
+	}
+
+	for _, tt := range tests {
+		lexer := lexer.New(tt.input)
+		parser := New(lexer)
+		program := parser.ParseProgram()
+		checkParserErrors(t, parser)
+
+		statement := program.Statements[0].(*ast.ExpressionStatement)
+		expression, ok := statement.Expression.(*ast.AssignExpression)
+		if !ok {
+			t.Fatalf("statement.Expression is not ast.AssignExpression. got=%T", statement.Expression)
+		}
+
+		if !testIdentifier(t, expression.Name, "x") {
+			return
+		}
+
+		if expression.Operator != tt.expectedOperator {
+			t.Errorf("expression.Operator is not %q. got=%q", tt.expectedOperator, expression.Operator)
+		}
+
+		if !testLiteralExpression(t, expression.Value, tt.expectedValue) {
+			return
+		}
+	}
+}
+
+func TestIndexAssignExpression(t *testing.T) {
+	input := `h["k"] += 1`
+
+	lexer := lexer.New(input)
+	parser := New(lexer)
+	program := parser.ParseProgram()
+	checkParserErrors(t, parser)
+
+	statement := program.Statements[0].(*ast.ExpressionStatement)
+	expression, ok := statement.Expression.(*ast.IndexAssignExpression)
+	if !ok {
+		t.Fatalf("statement.Expression is not ast.IndexAssignExpression. got=%T", statement.Expression)
+	}
+
+	if !testIdentifier(t, expression.Left, "h") {
+		return
+	}
+
+	if expression.Index.String() != "k" {
+		t.Errorf("expression.Index is not %q. got=%q", "k", expression.Index.String())
+	}
+
+	if expression.Operator != "+=" {
+		t.Errorf("expression.Operator is not %q. got=%q", "+=", expression.Operator)
+	}
+
+	testIntegerLiteral(t, expression.Value, 1)
+}
+
+func TestInvalidAssignmentTarget(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"1 = 2", "1:3: cannot assign to 1"},
+		{"a + b = 2", "1:7: cannot assign to (a + b)"},
+		{"f() += 1", "1:5: cannot assign to f()"},
+		{"a?[0] = 1", "1:7: cannot assign to (a?[0])"},
+	}
+
+	for _, tt := range tests {
+		lexer := lexer.New(tt.input)
+		parser := New(lexer)
+		parser.ParseProgram()
+
+		errors := parser.Errors()
+		if len(errors) != 1 {
+			t.Errorf("%q: expected 1 parser error, got=%d (%v)", tt.input, len(errors), errors)
+			continue
+		}
+
+		if errors[0].Error() != tt.expectedError {
+			t.Errorf("%q: wrong error. expected=%q, got=%q", tt.input, tt.expectedError, errors[0].Error())
+		}
+	}
+}
+
+func TestLoopControlOutsideOfLoop(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"break;", "1:1: break outside of loop"},
+		{"if (true) { continue; }", "1:13: continue outside of loop"},
+		{"while (true) { fn() { break; } }", "1:23: break outside of loop"},
+		{"for (x in y) { fn() { continue; } }", "1:23: continue outside of loop"},
+	}
+
+	for _, tt := range tests {
+		lexer := lexer.New(tt.input)
+		parser := New(lexer)
+		parser.ParseProgram()
+
+		errors := parser.Errors()
+		if len(errors) != 1 {
+			t.Errorf("%q: expected 1 parser error, got=%d (%v)", tt.input, len(errors), errors)
+			continue
+		}
+
+		if errors[0].Error() != tt.expectedError {
+			t.Errorf("%q: wrong error. expected=%q, got=%q", tt.input, tt.expectedError, errors[0].Error())
+		}
+	}
+}
+
+func TestIllegalTokens(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{`let x = "abc;`, "1:9: unterminated string literal"},
+		{"let x = `abc;", "1:9: unterminated raw string literal"},
+		{`puts("a\qb");`, "1:6: invalid escape sequence \\q"},
+		{"let x = 5 & 3;", "1:11: illegal character \"&\""},
+		{"let x = 5; /* never closed", "1:12: unterminated block comment"},
+		{"a ? b : c", "1:3: illegal character \"?\""},
+		{"a?.b", "1:4: expected ( or [ after ?., got IDENTIFIER instead"},
+	}
+
+	for _, tt := range tests {
+		lexer := lexer.New(tt.input)
+		parser := New(lexer)
+		parser.ParseProgram()
+
+		errors := parser.Errors()
+		if len(errors) != 1 {
+			t.Errorf("%q: expected 1 parser error, got=%d (%v)", tt.input, len(errors), errors)
+			continue
+		}
+
+		if errors[0].Error() != tt.expectedError {
+			t.Errorf("%q: wrong error. expected=%q, got=%q", tt.input, tt.expectedError, errors[0].Error())
+		}
+	}
+}
+
 func TestFunctionExpressionParsing(t *testing.T) {
 	input := `fn(x, y) { x + y; }`
 
@@ -596,6 +1251,108 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestFunctionParameterDefaultsAndRest(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedString   string
+		expectedRequired int
+		expectedRest     string
+	}{
+		{"fn(x, y = 2) {}", "fn(x, y = 2)", 1, ""},
+		{"fn(x = 1, y = x * 2) {}", "fn(x = 1, y = (x * 2))", 0, ""},
+		{"fn(...rest) {}", "fn(...rest)", 0, "rest"},
+		{"fn(x, y = 2, ...rest) {}", "fn(x, y = 2, ...rest)", 1, "rest"},
+	}
+
+	for _, tt := range tests {
+		lexer := lexer.New(tt.input)
+		parser := New(lexer)
+		program := parser.ParseProgram()
+		checkParserErrors(t, parser)
+
+		statement := program.Statements[0].(*ast.ExpressionStatement)
+		function := statement.Expression.(*ast.FunctionExpression)
+
+		if function.String() != tt.expectedString {
+			t.Errorf("wrong string. expected=%q, got=%q", tt.expectedString, function.String())
+		}
+
+		if function.NumRequired() != tt.expectedRequired {
+			t.Errorf("wrong number of required parameters. expected=%d, got=%d", tt.expectedRequired, function.NumRequired())
+		}
+
+		if tt.expectedRest == "" {
+			if function.Rest != nil {
+				t.Errorf("function.Rest is not nil. got=%s", function.Rest)
+			}
+		} else if function.Rest == nil || function.Rest.Value != tt.expectedRest {
+			t.Errorf("wrong rest parameter. expected=%s, got=%v", tt.expectedRest, function.Rest)
+		}
+	}
+}
+
+func TestFunctionParameterErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"fn(x = 1, y) {}", "1:11: required parameter y can't follow parameters with defaults"},
+		{"fn(...rest, x) {}", "1:11: expected next token to be ), got , instead"},
+		{"fn(1) {}", "1:4: expected next token to be IDENTIFIER, got INT instead"},
+		{"fn(x y) {}", "1:6: expected next token to be ,, got IDENTIFIER instead"},
+		{"fn(...) {}", "1:7: expected next token to be IDENTIFIER, got ) instead"},
+		{"f(x: 1, 2)", "1:9: positional arguments can't follow named arguments"},
+		{"f(x: 1, x: 2)", "1:9: duplicate named argument x"},
+		{"f(...xs, y: 2)", "1:10: named arguments can't be combined with spread arguments"},
+		{"f(x: 1, ...ys)", "1:9: named arguments can't be combined with spread arguments"},
+		{"f(x: )", "1:6: No prefix parse function was found for ')'."},
+		{"f?.(x: 1, 2)", "1:11: positional arguments can't follow named arguments"},
+	}
+
+	for _, tt := range tests {
+		lexer := lexer.New(tt.input)
+		parser := New(lexer)
+		parser.ParseProgram()
+
+		errors := parser.Errors()
+		if len(errors) == 0 {
+			t.Errorf("%q: expected a parser error", tt.input)
+			continue
+		}
+
+		if errors[0].Error() != tt.expectedError {
+			t.Errorf("%q: wrong error. expected=%q, got=%q", tt.input, tt.expectedError, errors[0].Error())
+		}
+	}
+}
+
+func TestFunctionExpressionWithName(t *testing.T) {
+	input := `let myFunction = fn() { };`
+
+	lexer := lexer.New(input)
+	parser := New(lexer)
+	program := parser.ParseProgram()
+	checkParserErrors(t, parser)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n", 1, len(program.Statements))
+	}
+
+	statement, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.LetStatement. got=%T", program.Statements[0])
+	}
+
+	function, ok := statement.Value.(*ast.FunctionExpression)
+	if !ok {
+		t.Fatalf("statement.Value is not ast.FunctionExpression. got=%T", statement.Value)
+	}
+
+	if function.Name != "myFunction" {
+		t.Fatalf("function name wrong. expected 'myFunction', got=%q\n", function.Name)
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5);"
 
@@ -652,6 +1409,16 @@ func TestCallExpressionParameterParsing(t *testing.T) {
 			expectedIdent: "add",
 			expectedArgs:  []string{"1", "(2 * 3)", "(4 + 5)"},
 		},
+		{
+			input:         "add(...xs, 1, ...[2]);",
+			expectedIdent: "add",
+			expectedArgs:  []string{"...xs", "1", "...[2]"},
+		},
+		{
+			input:         "add(1, y: 2 * 3, z: w);",
+			expectedIdent: "add",
+			expectedArgs:  []string{"1", "y: (2 * 3)", "z: w"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -705,6 +1472,71 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestInterpolatedStringExpression(t *testing.T) {
+	input := `"hello ${name}, you have ${len(items) + 1} items"`
+
+	lexer := lexer.New(input)
+	parser := New(lexer)
+	program := parser.ParseProgram()
+	checkParserErrors(t, parser)
+
+	statement := program.Statements[0].(*ast.ExpressionStatement)
+	interpolated, ok := statement.Expression.(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("expression is not *ast.InterpolatedString. got=%T", statement.Expression)
+	}
+
+	if len(interpolated.Parts) != 5 {
+		t.Fatalf("wrong number of parts. expected=5, got=%d", len(interpolated.Parts))
+	}
+
+	expectedText := map[int]string{0: "hello ", 2: ", you have ", 4: " items"}
+	for i, text := range expectedText {
+		literal, ok := interpolated.Parts[i].(*ast.StringLiteral)
+		if !ok || literal.Value != text {
+			t.Errorf("parts[%d] is not the string %q. got=%s", i, text, interpolated.Parts[i])
+		}
+	}
+
+	testIdentifier(t, interpolated.Parts[1], "name")
+
+	if interpolated.Parts[3].String() != "(len(items) + 1)" {
+		t.Errorf("parts[3] wrong. got=%s", interpolated.Parts[3])
+	}
+
+	if interpolated.String() != `"hello ${name}, you have ${(len(items) + 1)} items"` {
+		t.Errorf("interpolated.String() wrong. got=%s", interpolated.String())
+	}
+}
+
+func TestInterpolatedStringErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{`"a ${} b"`, "1:6: empty interpolation"},
+		{`"a ${1 2} b"`, "1:8: expected } to close the interpolation, got INT instead"},
+		{`"a ${x`, "1:7: expected } to close the interpolation, got EOF instead"},
+		{`"a ${x} b`, "1:7: unterminated string literal"},
+	}
+
+	for _, tt := range tests {
+		lexer := lexer.New(tt.input)
+		parser := New(lexer)
+		parser.ParseProgram()
+
+		errors := parser.Errors()
+		if len(errors) != 1 {
+			t.Errorf("%q: expected 1 parser error, got=%d (%v)", tt.input, len(errors), errors)
+			continue
+		}
+
+		if errors[0].Error() != tt.expectedError {
+			t.Errorf("%q: wrong error. expected=%q, got=%q", tt.input, tt.expectedError, errors[0].Error())
+		}
+	}
+}
+
 func TestParsingArrayLiterals(t *testing.T) {
 	input := "[1, 2*2,3+3]"
 
@@ -5,18 +5,60 @@ import (
 	"fmt"
 	"io"
 	"monkey-lang/compiler"
+	"monkey-lang/evaluator"
 	"monkey-lang/lexer"
+	"monkey-lang/object"
 	"monkey-lang/parser"
 	"monkey-lang/vm"
 )
 
 const PROMPT = ">> "
 
+// The engines a session can run on
+const (
+	ENGINE_VM   = "vm"
+	ENGINE_EVAL = "eval"
+)
+
+// Config selects how a session runs the lines it reads
+type Config struct {
+	Engine          string
+	IntegerOverflow object.IntegerOverflow
+}
+
+// Start runs an interactive session on the bytecode VM
 func Start(in io.Reader, out io.Writer) {
+	StartWithEngine(in, out, ENGINE_VM)
+}
+
+// StartWithEngine runs an interactive session on the given engine
+func StartWithEngine(in io.Reader, out io.Writer, engine string) {
+	StartWithConfig(in, out, Config{Engine: engine})
+}
+
+// StartWithConfig runs an interactive session configured by config. The
+// output of the programs goes to out as well
+func StartWithConfig(in io.Reader, out io.Writer, config Config) {
+	if config.Engine == ENGINE_EVAL {
+		startEval(in, out, config)
+	} else {
+		startVm(in, out, config)
+	}
+}
+
+func startVm(in io.Reader, out io.Writer, config Config) {
 	scanner := bufio.NewScanner(in)
 
+	// State shared between the lines of a session
+	constants := []object.Object{}
+	globals := make([]object.Object, vm.GlobalsSize)
+	symbolTable := compiler.NewSymbolTable()
+	for i, definition := range object.Builtins {
+		symbolTable.DefineBuiltin(i, definition.Name)
+	}
+
 	for {
-		fmt.Printf(PROMPT)
+		fmt.Fprint(out, PROMPT)
 		scanned := scanner.Scan()
 		if !scanned {
 			return
@@ -32,23 +74,75 @@ func Start(in io.Reader, out io.Writer) {
 			continue
 		}
 
-		compiler := compiler.New()
+		// Compile against a copy so the names a failing line defines, which
+		// would never get a value, don't outlive it
+		lineSymbolTable := symbolTable.Clone()
+		compiler := compiler.NewWithState(lineSymbolTable, constants)
 		err := compiler.Compile(program)
 		if err != nil {
 			fmt.Fprintf(out, "Woops! Compilation failed:\n %s\n", err)
 			continue
 		}
 
-		machine := vm.New(compiler.Bytecode())
+		bytecode := compiler.Bytecode()
+		constants = bytecode.Constants
+
+		machine := vm.NewWithGlobalsStore(bytecode, globals)
+		machine.SetIntegerOverflow(config.IntegerOverflow)
+		machine.SetOutput(out)
 		err = machine.Run()
+
+		lineSymbolTable.DropUnsetGlobals(symbolTable, globals)
+		symbolTable = lineSymbolTable
+
 		if err != nil {
 			fmt.Fprintf(out, "Woops! Executing bytecode failed:\n %s\n", err)
+			if runtimeError, ok := err.(*vm.RuntimeError); ok {
+				printStackTrace(out, runtimeError.StackTrace)
+			}
+			continue
+		}
+
+		lastPopped := machine.LastPoppedStackElem()
+		if lastPopped != nil {
+			io.WriteString(out, lastPopped.Inspect())
+			io.WriteString(out, "\n")
+		}
+	}
+}
+
+func startEval(in io.Reader, out io.Writer, config Config) {
+	scanner := bufio.NewScanner(in)
+	environment := object.NewEnvironment()
+	environment.SetIntegerOverflow(config.IntegerOverflow)
+	environment.SetOutput(out)
+
+	for {
+		fmt.Fprint(out, PROMPT)
+		scanned := scanner.Scan()
+		if !scanned {
+			return
+		}
+
+		line := scanner.Text()
+		lexer := lexer.New(line)
+		parser := parser.New(lexer)
+
+		program := parser.ParseProgram()
+		if len(parser.Errors()) != 0 {
+			printParserErrors(out, parser.Errors())
 			continue
 		}
 
-		stackTop := machine.StackTop()
-		io.WriteString(out, stackTop.Inspect())
-		io.WriteString(out, "\n")
+		evaluated := evaluator.Eval(program, environment)
+		if evaluated != nil {
+			io.WriteString(out, evaluated.Inspect())
+			io.WriteString(out, "\n")
+		}
+
+		if errorObject, ok := evaluated.(*object.Error); ok {
+			printStackTrace(out, errorObject.StackTrace)
+		}
 	}
 }
 
@@ -65,11 +159,17 @@ const MONKEY_FACE = `            __,__
            '-----'
 `
 
-func printParserErrors(out io.Writer, errors []string) {
+func printParserErrors(out io.Writer, errors []*parser.ParseError) {
 	io.WriteString(out, MONKEY_FACE)
 	io.WriteString(out, "Woops! We ran into some monkey business here!\n")
 	io.WriteString(out, " parser errors:\n")
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+	for _, err := range errors {
+		io.WriteString(out, "\t"+err.Error()+"\n")
+	}
+}
+
+func printStackTrace(out io.Writer, trace []object.StackFrame) {
+	for _, frame := range trace {
+		io.WriteString(out, "\t"+frame.String()+"\n")
 	}
 }
@@ -0,0 +1,57 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestVmSessionForgetsNamesOfFailedLines(t *testing.T) {
+	input := strings.Join([]string{
+		"let x = 1; zz;",
+		"x + 1",
+		"let y = len(1);",
+		"y + 1",
+		"let a = 5; let b = len(1);",
+		"a",
+	}, "\n")
+
+	var out bytes.Buffer
+	StartWithConfig(strings.NewReader(input), &out, Config{Engine: ENGINE_VM})
+
+	expected := []string{
+		"undefined variable zz",
+		"undefined variable x",
+		"Invalid argument passed to `len()`. Got=INTEGER",
+		"undefined variable y",
+		"Invalid argument passed to `len()`. Got=INTEGER",
+		">> 5\n",
+	}
+
+	output := out.String()
+	for _, part := range expected {
+		index := strings.Index(output, part)
+		if index < 0 {
+			t.Fatalf("output is missing %q. got=%q", part, out.String())
+		}
+		output = output[index+len(part):]
+	}
+}
+
+func TestLoopsEvaluateToNull(t *testing.T) {
+	input := strings.Join([]string{
+		"let i = 0; while (i < 2) { i = i + 1 }",
+		"for (x in [1, 2]) { x }",
+		"for (x in [1, 2]) { break }",
+	}, "\n")
+
+	for _, engine := range []string{ENGINE_VM, ENGINE_EVAL} {
+		var out bytes.Buffer
+		StartWithConfig(strings.NewReader(input), &out, Config{Engine: engine})
+
+		expected := strings.Repeat(PROMPT+"null\n", 3) + PROMPT
+		if out.String() != expected {
+			t.Errorf("%s: wrong output. expected=%q, got=%q", engine, expected, out.String())
+		}
+	}
+}
@@ -1,26 +1,64 @@
 package token
 
+import "fmt"
+
 type TokenType string
 
 type Token struct {
-	Type    TokenType
-	Literal string
+	Type     TokenType
+	Literal  string
+	Position Position // where the token starts in the source
+}
+
+type Position struct {
+	Filename string // empty when the source was not read from a file
+	Line     int    // starting at 1
+	Column   int    // starting at 1, counted in characters
+	Offset   int    // starting at 0, counted in bytes
+}
+
+// IsValid reports whether the position was set by the lexer
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Position) String() string {
+	if !p.IsValid() {
+		if p.Filename != "" {
+			return p.Filename
+		}
+		return "-"
+	}
+
+	if p.Filename != "" {
+		return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
 }
 
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
+	COMMENT = "COMMENT" // never returned by NextToken, see Lexer.Comments
 
 	IDENTIFIER = "IDENTIFIER"
 	INTEGER    = "INT"
+	FLOAT      = "FLOAT"
 	STRING     = "STRING"
 
+	// The pieces of an interpolated string like "a ${x} b ${y} c", which is
+	// lexed as STRINGHEAD("a "), x, STRINGMIDDLE(" b "), y, STRINGTAIL(" c")
+	STRINGHEAD   = "STRINGHEAD"
+	STRINGMIDDLE = "STRINGMIDDLE"
+	STRINGTAIL   = "STRINGTAIL"
+
 	ASSIGNMENT  = "="
 	PLUS        = "+"
 	MINUS       = "-"
 	BANG        = "!"
 	ASTERISK    = "*"
 	SLASH       = "/"
+	PERCENT     = "%"
 	LESSTHAN    = "<"
 	GREATERTHAN = ">"
 
@@ -34,26 +72,57 @@ const (
 	OPENBRACKET      = "["
 	CLOSEBRACKET     = "]"
 
-	EQUAL    = "=="
-	NOTEQUAL = "!="
+	EQUAL              = "=="
+	NOTEQUAL           = "!="
+	LESSTHANOREQUAL    = "<="
+	GREATERTHANOREQUAL = ">="
+
+	AND = "&&"
+	OR  = "||"
+
+	ARROW    = "=>"
+	ELLIPSIS = "..."
+
+	NULLCOALESCE    = "??"
+	QUESTIONDOT     = "?."
+	QUESTIONBRACKET = "?["
+
+	PLUSASSIGNMENT  = "+="
+	MINUSASSIGNMENT = "-="
 
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
+	CONST    = "CONST"
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
+	WHILE    = "WHILE"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	FOR      = "FOR"
+	IN       = "IN"
+	NULL     = "NULL"
+	MATCH    = "MATCH"
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"const":    CONST,
+	"true":     TRUE,
+	"false":    FALSE,
+	"null":     NULL,
+	"match":    MATCH,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"while":    WHILE,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"for":      FOR,
+	"in":       IN,
 }
 
 func LookupIdentifier(identifier string) TokenType {
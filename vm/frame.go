@@ -0,0 +1,36 @@
+package vm
+
+import (
+	"monkey-lang/code"
+	"monkey-lang/object"
+	"monkey-lang/token"
+)
+
+type Frame struct {
+	cl          *object.Closure
+	ip          int   // the instruction pointer within this frame's function
+	basePointer int   // the stack pointer before the function was called, locals are stored above it
+	loops       []int // the stack pointer when each loop running in this frame started, innermost last
+}
+
+func NewFrame(cl *object.Closure, basePointer int) *Frame {
+	return &Frame{cl: cl, ip: -1, basePointer: basePointer}
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
+
+// Position returns the source position of the instruction the frame is executing
+func (f *Frame) Position() token.Position {
+	sourceMap := f.cl.Fn.SourceMap
+
+	// ip may already point past the operands of the instruction
+	for offset := f.ip; offset >= 0; offset-- {
+		if position, ok := sourceMap[offset]; ok {
+			return position
+		}
+	}
+
+	return token.Position{}
+}
@@ -2,19 +2,34 @@ package vm
 
 import (
 	"fmt"
+	"io"
+	"math"
 	"monkey-lang/code"
 	"monkey-lang/compiler"
 	"monkey-lang/object"
+	"monkey-lang/token"
+	"os"
+	"strings"
 )
 
 const StackSize = 2048
+const GlobalsSize = 65536
+const MaxFrames = 1024
 
 type VM struct {
-	constants    []object.Object
-	instructions code.Instructions
+	constants []object.Object
 
 	stack []object.Object
 	sp    int // Always points to the next value. Top of stack is stack[sp - 1]
+
+	globals     []object.Object
+	globalNames []string // the name of each global, by index, for errors
+
+	frames      []*Frame
+	framesIndex int // Always points to the next frame. The current frame is frames[framesIndex - 1]
+
+	integerOverflow object.IntegerOverflow
+	output          io.Writer // where builtins like puts() write
 }
 
 // Boolean values: immutable, unique values
@@ -25,12 +40,59 @@ var False = &object.Boolean{Value: false}
 var Null = &object.Null{}
 
 func New(bytecode *compiler.Bytecode) *VM {
+	mainFunction := &object.CompiledFunction{Instructions: bytecode.Instructions, SourceMap: bytecode.SourceMap}
+	mainClosure := &object.Closure{Fn: mainFunction}
+	mainFrame := NewFrame(mainClosure, 0)
+
+	frames := make([]*Frame, MaxFrames)
+	frames[0] = mainFrame
+
 	return &VM{
-		instructions: bytecode.Instructions,
-		constants:    bytecode.Constants,
-		stack:        make([]object.Object, StackSize),
-		sp:           0,
+		constants:   bytecode.Constants,
+		stack:       make([]object.Object, StackSize),
+		sp:          0,
+		globals:     make([]object.Object, GlobalsSize),
+		globalNames: bytecode.GlobalNames,
+		output:      os.Stdout,
+		frames:      frames,
+		framesIndex: 1,
+	}
+}
+
+func NewWithGlobalsStore(bytecode *compiler.Bytecode, globals []object.Object) *VM {
+	vm := New(bytecode)
+	vm.globals = globals
+	return vm
+}
+
+// SetIntegerOverflow sets what integer arithmetic does when a result doesn't fit in an int64
+func (vm *VM) SetIntegerOverflow(overflow object.IntegerOverflow) {
+	vm.integerOverflow = overflow
+}
+
+// SetOutput sets where builtins like puts() write
+func (vm *VM) SetOutput(output io.Writer) {
+	vm.output = output
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) error {
+	if vm.framesIndex >= MaxFrames {
+		return fmt.Errorf("frame overflow")
 	}
+
+	vm.frames[vm.framesIndex] = f
+	vm.framesIndex++
+
+	return nil
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
 }
 
 func (vm *VM) StackTop() object.Object {
@@ -41,14 +103,66 @@ func (vm *VM) StackTop() object.Object {
 	return vm.stack[vm.sp-1]
 }
 
+// RuntimeError is an error raised while executing bytecode
+type RuntimeError struct {
+	Message    string
+	Position   token.Position      // the source position of the failing instruction, if known
+	StackTrace []object.StackFrame // the calls that led to the error, innermost first
+}
+
+func (e *RuntimeError) Error() string {
+	if e.Position.IsValid() {
+		return e.Position.String() + ": " + e.Message
+	}
+
+	return e.Message
+}
+
 func (vm *VM) Run() error {
-	for ip := 0; ip < len(vm.instructions); ip++ {
-		op := code.Opcode(vm.instructions[ip])
+	err := vm.run()
+	if err != nil {
+		return &RuntimeError{
+			Message:    err.Error(),
+			Position:   vm.currentFrame().Position(),
+			StackTrace: vm.stackTrace(),
+		}
+	}
+
+	return nil
+}
+
+// stackTrace describes the frames of the called functions, each frame was called
+// from the position its caller frame is at
+func (vm *VM) stackTrace() []object.StackFrame {
+	trace := []object.StackFrame{}
+
+	for i := vm.framesIndex - 1; i > 0; i-- {
+		frame := object.StackFrame{
+			Function: vm.frames[i].cl.Fn.Name,
+			Position: vm.frames[i-1].Position(),
+		}
+		trace = append(trace, frame)
+	}
+
+	return trace
+}
+
+func (vm *VM) run() error {
+	var ip int
+	var instructions code.Instructions
+	var op code.Opcode
+
+	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+
+		ip = vm.currentFrame().ip
+		instructions = vm.currentFrame().Instructions()
+		op = code.Opcode(instructions[ip])
 
 		switch op {
 		case code.OpConstant:
-			constIndex := code.ReadUint16(vm.instructions[ip+1:])
-			ip += 2
+			constIndex := code.ReadUint16(instructions[ip+1:])
+			vm.currentFrame().ip += 2
 
 			err := vm.push(vm.constants[constIndex])
 			if err != nil {
@@ -69,12 +183,13 @@ func (vm *VM) Run() error {
 			if err != nil {
 				return err
 			}
-		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv:
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod:
 			err := vm.executeBinaryOperation(op)
 			if err != nil {
 				return err
 			}
-		case code.OpEqual, code.OpNotEqual, code.OpGreaterThan:
+		case code.OpEqual, code.OpNotEqual, code.OpGreaterThan,
+			code.OpLessThan, code.OpGreaterThanOrEqual, code.OpLessThanOrEqual:
 			err := vm.executeComparison(op)
 			if err != nil {
 				return err
@@ -89,18 +204,355 @@ func (vm *VM) Run() error {
 			if err != nil {
 				return err
 			}
+		case code.OpPlus:
+			err := vm.executePlusOperator()
+			if err != nil {
+				return err
+			}
 		case code.OpPop:
 			vm.pop()
 		case code.OpJump:
-			pos := int(code.ReadUint16(vm.instructions[ip+1:]))
-			ip = pos - 1
+			pos := int(code.ReadUint16(instructions[ip+1:]))
+			vm.currentFrame().ip = pos - 1
 		case code.OpJumpNotTruthy:
-			pos := int(code.ReadUint16(vm.instructions[ip+1:]))
-			ip += 2
+			pos := int(code.ReadUint16(instructions[ip+1:]))
+			vm.currentFrame().ip += 2
 
 			condition := vm.pop()
 			if !isTruthy(condition) {
-				ip = pos - 1
+				vm.currentFrame().ip = pos - 1
+			}
+		case code.OpJumpTable:
+			constIndex := code.ReadUint16(instructions[ip+1:])
+			vm.currentFrame().ip += 2
+
+			table := vm.constants[constIndex].(*object.Hash)
+			if key, ok := vm.pop().(object.Hashable); ok {
+				if pair, ok := table.Pairs[key.HashKey()]; ok {
+					vm.currentFrame().ip = int(pair.Value.(*object.Integer).Value) - 1
+				}
+			}
+		case code.OpMatchArray:
+			length := int(code.ReadUint16(instructions[ip+1:]))
+			hasRest := code.ReadUint8(instructions[ip+3:]) == 1
+			vm.currentFrame().ip += 3
+
+			array, ok := vm.pop().(*object.Array)
+			matched := ok && (len(array.Elements) == length || hasRest && len(array.Elements) > length)
+
+			err := vm.push(nativeBoolToBooleanObject(matched))
+			if err != nil {
+				return err
+			}
+		case code.OpUnpackArray:
+			numElements := int(code.ReadUint16(instructions[ip+1:]))
+			hasRest := code.ReadUint8(instructions[ip+3:]) == 1
+			vm.currentFrame().ip += 3
+
+			err := vm.unpackArray(vm.pop(), numElements, hasRest)
+			if err != nil {
+				return err
+			}
+		case code.OpUnpackHash:
+			numKeys := int(code.ReadUint16(instructions[ip+1:]))
+			hasRest := code.ReadUint8(instructions[ip+3:]) == 1
+			vm.currentFrame().ip += 3
+
+			keys := make([]object.Object, numKeys)
+			copy(keys, vm.stack[vm.sp-numKeys:vm.sp])
+			value := vm.stack[vm.sp-numKeys-1]
+			vm.sp = vm.sp - numKeys - 1
+
+			err := vm.unpackHash(value, keys, hasRest)
+			if err != nil {
+				return err
+			}
+		case code.OpMatchHash:
+			numKeys := int(code.ReadUint16(instructions[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			matched := vm.matchHash(vm.stack[vm.sp-numKeys-1], vm.stack[vm.sp-numKeys:vm.sp])
+			vm.sp = vm.sp - numKeys - 1
+
+			err := vm.push(nativeBoolToBooleanObject(matched))
+			if err != nil {
+				return err
+			}
+		case code.OpJumpNull:
+			pos := int(code.ReadUint16(instructions[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if vm.pop() == Null {
+				vm.currentFrame().ip = pos - 1
+			}
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(instructions[ip+1:])
+			vm.currentFrame().ip += 2
+
+			vm.globals[globalIndex] = vm.pop()
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(instructions[ip+1:])
+			vm.currentFrame().ip += 2
+
+			value := vm.globals[globalIndex]
+			if value == nil {
+				return unsetError(vm.globalNames, int(globalIndex))
+			}
+			err := vm.push(value)
+			if err != nil {
+				return err
+			}
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(instructions[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			slot := frame.basePointer + int(localIndex)
+			if cell, ok := vm.stack[slot].(*object.Cell); ok {
+				cell.Value = vm.pop()
+			} else {
+				vm.stack[slot] = vm.pop()
+			}
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(instructions[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			value := vm.stack[frame.basePointer+int(localIndex)]
+			if cell, ok := value.(*object.Cell); ok {
+				value = cell.Value
+			}
+			if value == nil {
+				return unsetError(frame.cl.Fn.LocalNames, int(localIndex))
+			}
+			err := vm.push(value)
+			if err != nil {
+				return err
+			}
+		case code.OpCaptureLocal:
+			localIndex := code.ReadUint8(instructions[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			slot := frame.basePointer + int(localIndex)
+			cell, ok := vm.stack[slot].(*object.Cell)
+			if !ok {
+				cell = &object.Cell{Value: vm.stack[slot]}
+				vm.stack[slot] = cell
+			}
+			err := vm.push(cell)
+			if err != nil {
+				return err
+			}
+		case code.OpArray:
+			numElements := int(code.ReadUint16(instructions[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			array := vm.buildArray(vm.sp-numElements, vm.sp)
+			vm.sp = vm.sp - numElements
+
+			err := vm.push(array)
+			if err != nil {
+				return err
+			}
+		case code.OpInterpolate:
+			numParts := int(code.ReadUint16(instructions[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			str := vm.buildString(vm.sp-numParts, vm.sp)
+			vm.sp = vm.sp - numParts
+
+			err := vm.push(str)
+			if err != nil {
+				return err
+			}
+		case code.OpHash:
+			numElements := int(code.ReadUint16(instructions[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			hash, err := vm.buildHash(vm.sp-numElements, vm.sp)
+			if err != nil {
+				return err
+			}
+			vm.sp = vm.sp - numElements
+
+			err = vm.push(hash)
+			if err != nil {
+				return err
+			}
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+
+			err := vm.executeIndexExpression(left, index)
+			if err != nil {
+				return err
+			}
+		case code.OpEnterLoop:
+			frame := vm.currentFrame()
+			frame.loops = append(frame.loops, vm.sp)
+		case code.OpUnwindLoop:
+			frame := vm.currentFrame()
+			vm.sp = frame.loops[len(frame.loops)-1]
+		case code.OpLeaveLoop:
+			frame := vm.currentFrame()
+			frame.loops = frame.loops[:len(frame.loops)-1]
+		case code.OpIterInit:
+			iterable := vm.pop()
+
+			iterator, ok := object.NewIterator(iterable)
+			if !ok {
+				return fmt.Errorf("cannot iterate over %s", iterable.Type())
+			}
+
+			err := vm.push(iterator)
+			if err != nil {
+				return err
+			}
+		case code.OpIterNext:
+			pos := int(code.ReadUint16(instructions[ip+1:]))
+			numValues := code.ReadUint8(instructions[ip+3:])
+			vm.currentFrame().ip += 3
+
+			err := vm.executeIterNext(pos, int(numValues))
+			if err != nil {
+				return err
+			}
+		case code.OpSetIndex:
+			value := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+
+			err := vm.executeIndexAssignment(left, index, value)
+			if err != nil {
+				return err
+			}
+		case code.OpGetBuiltin:
+			builtinIndex := code.ReadUint8(instructions[ip+1:])
+			vm.currentFrame().ip += 1
+
+			definition := object.Builtins[builtinIndex]
+			err := vm.push(definition.Builtin)
+			if err != nil {
+				return err
+			}
+		case code.OpClosure:
+			constIndex := code.ReadUint16(instructions[ip+1:])
+			numFree := code.ReadUint8(instructions[ip+3:])
+			vm.currentFrame().ip += 3
+
+			err := vm.pushClosure(int(constIndex), int(numFree))
+			if err != nil {
+				return err
+			}
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(instructions[ip+1:])
+			vm.currentFrame().ip += 1
+
+			currentClosure := vm.currentFrame().cl
+			value := currentClosure.Free[freeIndex].Value
+			if value == nil {
+				return unsetError(currentClosure.Fn.FreeNames, int(freeIndex))
+			}
+			err := vm.push(value)
+			if err != nil {
+				return err
+			}
+		case code.OpSetFree:
+			freeIndex := code.ReadUint8(instructions[ip+1:])
+			vm.currentFrame().ip += 1
+
+			currentClosure := vm.currentFrame().cl
+			currentClosure.Free[freeIndex].Value = vm.pop()
+		case code.OpCaptureFree:
+			freeIndex := code.ReadUint8(instructions[ip+1:])
+			vm.currentFrame().ip += 1
+
+			currentClosure := vm.currentFrame().cl
+			err := vm.push(currentClosure.Free[freeIndex])
+			if err != nil {
+				return err
+			}
+		case code.OpDup:
+			count := int(code.ReadUint8(instructions[ip+1:]))
+			vm.currentFrame().ip += 1
+
+			start := vm.sp - count
+			for i := 0; i < count; i++ {
+				err := vm.push(vm.stack[start+i])
+				if err != nil {
+					return err
+				}
+			}
+		case code.OpCurrentClosure:
+			currentClosure := vm.currentFrame().cl
+			err := vm.push(currentClosure)
+			if err != nil {
+				return err
+			}
+		case code.OpCall:
+			numArgs := code.ReadUint8(instructions[ip+1:])
+			vm.currentFrame().ip += 1
+
+			err := vm.executeCall(int(numArgs))
+			if err != nil {
+				return err
+			}
+		case code.OpCallNamed:
+			numArgs := code.ReadUint8(instructions[ip+1:])
+			namesIndex := code.ReadUint16(instructions[ip+2:])
+			vm.currentFrame().ip += 3
+
+			err := vm.executeNamedCall(int(numArgs), vm.constants[namesIndex].(*object.Array))
+			if err != nil {
+				return err
+			}
+		case code.OpSpread:
+			value := vm.pop()
+			array, ok := value.(*object.Array)
+			if !ok {
+				return fmt.Errorf("cannot spread %s", value.Type())
+			}
+
+			arguments := vm.stack[vm.sp-1].(*object.Array)
+			arguments.Elements = append(arguments.Elements, array.Elements...)
+		case code.OpCallSpread:
+			arguments := vm.pop().(*object.Array)
+			for _, argument := range arguments.Elements {
+				err := vm.push(argument)
+				if err != nil {
+					return err
+				}
+			}
+
+			err := vm.executeCall(len(arguments.Elements))
+			if err != nil {
+				return err
+			}
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+
+			// A return outside of any function ends the program, its value
+			// stays the last popped element as the result
+			if vm.framesIndex == 1 {
+				return nil
+			}
+
+			frame := vm.popFrame()
+			// Discard the locals and the called function itself
+			vm.sp = frame.basePointer - 1
+
+			err := vm.push(returnValue)
+			if err != nil {
+				return err
+			}
+		case code.OpReturn:
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+
+			err := vm.push(Null)
+			if err != nil {
+				return err
 			}
 		}
 	}
@@ -108,6 +560,154 @@ func (vm *VM) Run() error {
 	return nil
 }
 
+func (vm *VM) executeCall(numArgs int) error {
+	// The callee sits right below its arguments on the stack
+	callee := vm.stack[vm.sp-1-numArgs]
+
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.Builtin:
+		return vm.callBuiltin(callee, numArgs)
+	default:
+		return fmt.Errorf("calling non-function")
+	}
+}
+
+// executeNamedCall calls the function below numArgs arguments on the stack,
+// the last of which are passed by the names in the names array
+func (vm *VM) executeNamedCall(numArgs int, names *object.Array) error {
+	callee := vm.stack[vm.sp-1-numArgs]
+
+	closure, ok := callee.(*object.Closure)
+	if !ok {
+		if _, ok := callee.(*object.Builtin); ok {
+			return fmt.Errorf("builtin functions don't take named arguments")
+		}
+		return fmt.Errorf("calling non-function")
+	}
+
+	numNamed := len(names.Elements)
+	nameValues := make([]string, numNamed)
+	for i, name := range names.Elements {
+		nameValues[i] = name.(*object.String).Value
+	}
+
+	fn := closure.Fn
+	positional := vm.stack[vm.sp-numArgs : vm.sp-numNamed]
+	named := vm.stack[vm.sp-numNamed : vm.sp]
+	args, msg := object.ArrangeArguments(fn.LocalNames[:fn.NumParameters], fn.NumRequired, positional, nameValues, named, Null)
+	if msg != "" {
+		return fmt.Errorf("%s", msg)
+	}
+
+	vm.sp = vm.sp - numArgs
+	for _, arg := range args {
+		err := vm.push(arg)
+		if err != nil {
+			return err
+		}
+	}
+
+	return vm.callClosure(closure, len(args))
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
+	fn := cl.Fn
+	if msg := object.CheckArity(fn.NumRequired, fn.NumParameters, fn.HasRest, numArgs); msg != "" {
+		return fmt.Errorf("%s", msg)
+	}
+
+	// Missing arguments are null until the function sets their defaults
+	for ; numArgs < fn.NumParameters; numArgs++ {
+		err := vm.push(Null)
+		if err != nil {
+			return err
+		}
+	}
+
+	if fn.HasRest {
+		numExtra := numArgs - fn.NumParameters
+		rest := vm.buildArray(vm.sp-numExtra, vm.sp)
+		vm.sp = vm.sp - numExtra
+
+		err := vm.push(rest)
+		if err != nil {
+			return err
+		}
+		numArgs = fn.NumParameters + 1
+	}
+
+	// The arguments become the first locals of the new frame
+	frame := NewFrame(cl, vm.sp-numArgs)
+	err := vm.pushFrame(frame)
+	if err != nil {
+		return err
+	}
+
+	if frame.basePointer+cl.Fn.NumLocals >= StackSize {
+		return fmt.Errorf("stack overflow")
+	}
+	// Clear the other locals, so that a cell left by an earlier call isn't
+	// mistaken for one of this frame's captured variables
+	for i := vm.sp; i < frame.basePointer+cl.Fn.NumLocals; i++ {
+		vm.stack[i] = nil
+	}
+	vm.sp = frame.basePointer + cl.Fn.NumLocals
+
+	return nil
+}
+
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
+	args := vm.stack[vm.sp-numArgs : vm.sp]
+
+	result := builtin.Function(vm.output, args...)
+	// Discard the arguments and the builtin itself
+	vm.sp = vm.sp - numArgs - 1
+
+	if err, ok := result.(*object.Error); ok {
+		return fmt.Errorf("%s", err.Message)
+	}
+
+	if result != nil {
+		return vm.push(result)
+	}
+	return vm.push(Null)
+}
+
+// unsetError reports reading the binding at index, which is defined but was
+// never set, e.g. by a `let` in a branch that didn't run or a loop that didn't
+// iterate
+func unsetError(names []string, index int) error {
+	if index < len(names) {
+		return fmt.Errorf("identifier not found: %s", names[index])
+	}
+
+	return fmt.Errorf("identifier not found")
+}
+
+func (vm *VM) pushClosure(constIndex int, numFree int) error {
+	constant := vm.constants[constIndex]
+	function, ok := constant.(*object.CompiledFunction)
+	if !ok {
+		return fmt.Errorf("not a function: %+v", constant)
+	}
+
+	free := make([]*object.Cell, numFree)
+	for i := 0; i < numFree; i++ {
+		captured := vm.stack[vm.sp-numFree+i]
+		cell, ok := captured.(*object.Cell)
+		if !ok {
+			cell = &object.Cell{Value: captured}
+		}
+		free[i] = cell
+	}
+	vm.sp = vm.sp - numFree
+
+	closure := &object.Closure{Fn: function, Free: free}
+	return vm.push(closure)
+}
+
 func (vm *VM) LastPoppedStackElem() object.Object {
 	return vm.stack[vm.sp]
 }
@@ -136,18 +736,48 @@ func (vm *VM) executeBinaryOperation(op code.Opcode) error {
 	leftType := left.Type()
 	rightType := right.Type()
 
-	if leftType == object.INTEGER_OBJ && rightType == object.INTEGER_OBJ {
+	switch {
+	case object.IsInteger(left) && object.IsInteger(right):
 		return vm.executeBinaryIntegerOperation(op, left, right)
+	case object.IsNumber(left) && object.IsNumber(right):
+		return vm.executeBinaryFloatOperation(op, left, right)
+	case leftType == object.STRING_OBJ && rightType == object.STRING_OBJ:
+		return vm.executeBinaryStringOperation(op, left, right)
 	}
 
 	return fmt.Errorf("unsupported types of binary operation: %s %s", leftType, rightType)
 }
 
+// The operators of the opcodes whose integer arithmetic is shared with the evaluator
+var integerOperators = map[code.Opcode]string{
+	code.OpAdd: "+",
+	code.OpSub: "-",
+	code.OpMul: "*",
+	code.OpDiv: "/",
+	code.OpMod: "%",
+}
+
 func (vm *VM) executeBinaryIntegerOperation(op code.Opcode, left, right object.Object) error {
-	leftValue := left.(*object.Integer).Value
-	rightValue := right.(*object.Integer).Value
+	operator, ok := integerOperators[op]
+	if !ok {
+		return fmt.Errorf("unknown integer operator: %d", op)
+	}
+
+	result := object.IntegerArithmetic(operator, left, right, vm.integerOverflow)
+	if err, ok := result.(*object.Error); ok {
+		return fmt.Errorf("%s", err.Message)
+	}
+
+	return vm.push(result)
+}
 
-	var result int64
+// executeBinaryFloatOperation handles arithmetic between two numbers of which
+// at least one is a float, promoting the other one to a float
+func (vm *VM) executeBinaryFloatOperation(op code.Opcode, left, right object.Object) error {
+	leftValue := object.ToFloat(left)
+	rightValue := object.ToFloat(right)
+
+	var result float64
 
 	switch op {
 	case code.OpAdd:
@@ -158,21 +788,42 @@ func (vm *VM) executeBinaryIntegerOperation(op code.Opcode, left, right object.O
 		result = leftValue * rightValue
 	case code.OpDiv:
 		result = leftValue / rightValue
+	case code.OpMod:
+		result = math.Mod(leftValue, rightValue)
 	default:
-		return fmt.Errorf("unknown integer operator: %d", op)
+		return fmt.Errorf("unknown float operator: %d", op)
+	}
+
+	return vm.push(&object.Float{Value: result})
+}
+
+func (vm *VM) executeBinaryStringOperation(op code.Opcode, left, right object.Object) error {
+	if op != code.OpAdd {
+		return fmt.Errorf("unknown string operator: %d", op)
 	}
 
-	return vm.push(&object.Integer{Value: result})
+	leftValue := left.(*object.String).Value
+	rightValue := right.(*object.String).Value
+
+	return vm.push(&object.String{Value: leftValue + rightValue})
 }
 
 func (vm *VM) executeComparison(op code.Opcode) error {
 	right := vm.pop()
 	left := vm.pop()
 
-	if left.Type() == object.INTEGER_OBJ || right.Type() == object.INTEGER_OBJ {
+	if object.IsInteger(left) && object.IsInteger(right) {
 		return vm.executeIntegerComparison(op, left, right)
 	}
 
+	if object.IsNumber(left) && object.IsNumber(right) {
+		return vm.executeFloatComparison(op, left, right)
+	}
+
+	if left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ {
+		return vm.executeStringComparison(op, left, right)
+	}
+
 	switch op {
 	case code.OpEqual:
 		return vm.push(nativeBoolToBooleanObject(right == left))
@@ -183,22 +834,249 @@ func (vm *VM) executeComparison(op code.Opcode) error {
 	}
 }
 
+// executeStringComparison compares strings by value. They have no ordering
+func (vm *VM) executeStringComparison(op code.Opcode, left, right object.Object) error {
+	leftValue := left.(*object.String).Value
+	rightValue := right.(*object.String).Value
+
+	switch op {
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue == rightValue))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue != rightValue))
+	default:
+		return fmt.Errorf("unknown operator: %d (%s %s)", op, left.Type(), right.Type())
+	}
+}
+
 func (vm *VM) executeIntegerComparison(op code.Opcode, left, right object.Object) error {
-	leftValue := left.(*object.Integer).Value
-	rightValue := right.(*object.Integer).Value
+	comparison := object.CompareIntegers(left, right)
 
 	switch op {
 	case code.OpEqual:
-		return vm.push(nativeBoolToBooleanObject(rightValue == leftValue))
+		return vm.push(nativeBoolToBooleanObject(comparison == 0))
 	case code.OpNotEqual:
-		return vm.push(nativeBoolToBooleanObject(rightValue != leftValue))
+		return vm.push(nativeBoolToBooleanObject(comparison != 0))
 	case code.OpGreaterThan:
-		return vm.push(nativeBoolToBooleanObject(leftValue > rightValue))
+		return vm.push(nativeBoolToBooleanObject(comparison > 0))
+	case code.OpLessThan:
+		return vm.push(nativeBoolToBooleanObject(comparison < 0))
+	case code.OpGreaterThanOrEqual:
+		return vm.push(nativeBoolToBooleanObject(comparison >= 0))
+	case code.OpLessThanOrEqual:
+		return vm.push(nativeBoolToBooleanObject(comparison <= 0))
 	default:
 		return fmt.Errorf("unknown operator: %d", op)
 	}
 }
 
+func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
+	elements := make([]object.Object, endIndex-startIndex)
+
+	for i := startIndex; i < endIndex; i++ {
+		elements[i-startIndex] = vm.stack[i]
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+func (vm *VM) buildString(startIndex, endIndex int) object.Object {
+	var out strings.Builder
+
+	for i := startIndex; i < endIndex; i++ {
+		out.WriteString(vm.stack[i].Inspect())
+	}
+
+	return &object.String{Value: out.String()}
+}
+
+// unpackArray pushes the first numElements elements of value, see OpUnpackArray
+func (vm *VM) unpackArray(value object.Object, numElements int, hasRest bool) error {
+	array, ok := value.(*object.Array)
+	if !ok {
+		return fmt.Errorf("cannot destructure %s as an array", value.Type())
+	}
+
+	if hasRest {
+		err := vm.push(array.From(numElements))
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := numElements - 1; i >= 0; i-- {
+		var element object.Object = Null
+		if i < len(array.Elements) {
+			element = array.Elements[i]
+		}
+
+		err := vm.push(element)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// unpackHash pushes the values of keys in value, see OpUnpackHash
+func (vm *VM) unpackHash(value object.Object, keys []object.Object, hasRest bool) error {
+	hash, ok := value.(*object.Hash)
+	if !ok {
+		return fmt.Errorf("cannot destructure %s as a hash", value.Type())
+	}
+
+	hashKeys := make([]object.HashKey, len(keys))
+	for i, key := range keys {
+		hashKeys[i] = key.(object.Hashable).HashKey()
+	}
+
+	if hasRest {
+		err := vm.push(hash.Without(hashKeys))
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := len(hashKeys) - 1; i >= 0; i-- {
+		var pairValue object.Object = Null
+		if pair, ok := hash.Pairs[hashKeys[i]]; ok {
+			pairValue = pair.Value
+		}
+
+		err := vm.push(pairValue)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// matchHash reports whether value is a hash with all of the keys
+func (vm *VM) matchHash(value object.Object, keys []object.Object) bool {
+	hash, ok := value.(*object.Hash)
+	if !ok {
+		return false
+	}
+
+	for _, key := range keys {
+		if _, ok := hash.Pairs[key.(object.Hashable).HashKey()]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
+	hashedPairs := make(map[object.HashKey]object.HashPair)
+
+	// Keys and values sit on the stack in alternating order
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return nil, fmt.Errorf("Unusable as hash key: %s", key.Type())
+		}
+
+		hashedPairs[hashKey.HashKey()] = object.HashPair{Key: key, Value: value}
+	}
+
+	return &object.Hash{Pairs: hashedPairs}, nil
+}
+
+func (vm *VM) executeIterNext(pos int, numValues int) error {
+	iterator := vm.stack[vm.sp-1].(*object.Iterator)
+
+	if !iterator.Next() {
+		vm.pop()
+		vm.currentFrame().ip = pos - 1
+		return nil
+	}
+
+	if numValues == 1 {
+		return vm.push(iterator.Element())
+	}
+
+	err := vm.push(iterator.Key())
+	if err != nil {
+		return err
+	}
+
+	return vm.push(iterator.Value())
+}
+
+func (vm *VM) executeIndexExpression(left, index object.Object) error {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		return vm.executeArrayIndex(left, index)
+	case left.Type() == object.HASH_OBJ:
+		return vm.executeHashIndex(left, index)
+	default:
+		return fmt.Errorf("Index operator is not defined on type: %s", left.Type())
+	}
+}
+
+func (vm *VM) executeArrayIndex(array, index object.Object) error {
+	arrayObject := array.(*object.Array)
+
+	idx := index.(*object.Integer).Value
+	max := int64(len(arrayObject.Elements) - 1)
+
+	if idx < 0 || idx > max {
+		return vm.push(Null)
+	}
+
+	return vm.push(arrayObject.Elements[idx])
+}
+
+func (vm *VM) executeHashIndex(hash, index object.Object) error {
+	hashObject := hash.(*object.Hash)
+
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return fmt.Errorf("Unusable as hash key: %s", index.Type())
+	}
+
+	pair, ok := hashObject.Pairs[key.HashKey()]
+	if !ok {
+		return vm.push(Null)
+	}
+
+	return vm.push(pair.Value)
+}
+
+func (vm *VM) executeIndexAssignment(left, index, value object.Object) error {
+	if object.IsFrozen(left) {
+		return fmt.Errorf("cannot modify frozen %s", left.Type())
+	}
+
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		arrayObject := left.(*object.Array)
+		i := index.(*object.Integer).Value
+		if i < 0 || i >= int64(len(arrayObject.Elements)) {
+			return fmt.Errorf("index out of range: %d", i)
+		}
+
+		arrayObject.Elements[i] = value
+	case left.Type() == object.HASH_OBJ:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return fmt.Errorf("Unusable as hash key: %s", index.Type())
+		}
+
+		left.(*object.Hash).Pairs[key.HashKey()] = object.HashPair{Key: index, Value: value}
+	default:
+		return fmt.Errorf("Index assignment is not defined on type: %s", left.Type())
+	}
+
+	return vm.push(value)
+}
+
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	if input {
 		return True
@@ -221,15 +1099,53 @@ func (vm *VM) executeBangOperator() error {
 	}
 }
 
+func (vm *VM) executeFloatComparison(op code.Opcode, left, right object.Object) error {
+	leftValue := object.ToFloat(left)
+	rightValue := object.ToFloat(right)
+
+	switch op {
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(rightValue == leftValue))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(rightValue != leftValue))
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue > rightValue))
+	case code.OpLessThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue < rightValue))
+	case code.OpGreaterThanOrEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue >= rightValue))
+	case code.OpLessThanOrEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue <= rightValue))
+	default:
+		return fmt.Errorf("unknown operator: %d", op)
+	}
+}
+
 func (vm *VM) executeMinusOperator() error {
 	operand := vm.pop()
 
-	if operand.Type() != object.INTEGER_OBJ {
+	switch operand := operand.(type) {
+	case *object.Integer, *object.BigInteger:
+		result := object.NegateInteger(operand, vm.integerOverflow)
+		if err, ok := result.(*object.Error); ok {
+			return fmt.Errorf("%s", err.Message)
+		}
+		return vm.push(result)
+	case *object.Float:
+		return vm.push(&object.Float{Value: -operand.Value})
+	default:
 		return fmt.Errorf("unsupported type for negation: %s", operand.Type())
 	}
+}
+
+func (vm *VM) executePlusOperator() error {
+	operand := vm.pop()
+
+	if !object.IsNumber(operand) {
+		return fmt.Errorf("unsupported type for unary plus: %s", operand.Type())
+	}
 
-	value := operand.(*object.Integer).Value
-	return vm.push(&object.Integer{Value: -value})
+	return vm.push(operand)
 }
 
 func isTruthy(obj object.Object) bool {
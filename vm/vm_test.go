@@ -1,12 +1,14 @@
 package vm
 
 import (
+	"bytes"
 	"fmt"
 	"monkey-lang/ast"
 	"monkey-lang/compiler"
 	"monkey-lang/lexer"
 	"monkey-lang/object"
 	"monkey-lang/parser"
+	"strings"
 	"testing"
 )
 
@@ -34,11 +36,83 @@ func TestIntegerArithmetic(t *testing.T) {
 		{"-10", -10},
 		{"-50 + 100 + -50", 0},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"+5", 5},
+		{"-+5", -5},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"7 % -3", 1},
+		{"2 + 10 % 4 * 3", 8},
 	}
 
 	runVmTests(t, tests)
 }
 
+func TestFloatArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"1.5", 1.5},
+		{"-2.5", -2.5},
+		{"+2.5", 2.5},
+		{"1.5 + 1.5", 3.0},
+		{"1 + 0.5", 1.5},
+		{"0.5 * 4", 2.0},
+		{"1 / 4.0", 0.25},
+		{"5.5 % 2", 1.5},
+		{"2 - 0.5 * 3", 0.5},
+		{"1e2 + 1", 101.0},
+		{"let x = 1; x += 0.5; x", 1.5},
+		{"{1.5: 1, 2: 2}[1.5]", 1},
+		{"{2.0: 1}[2]", Null},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestIntegerOverflow(t *testing.T) {
+	tests := []struct {
+		input    string
+		overflow object.IntegerOverflow
+		expected string
+	}{
+		{"9223372036854775807 + 1", object.OverflowError, "integer overflow: 9223372036854775807 + 1"},
+		{"-9223372036854775807 - 1 - 1", object.OverflowError, "integer overflow: -9223372036854775808 - 1"},
+		{"-(-9223372036854775807 - 1)", object.OverflowError, "integer overflow: -(-9223372036854775808)"},
+		{"let f = fn(x) { x * x }; f(4294967296)", object.OverflowError, "integer overflow: 4294967296 * 4294967296"},
+		{"1 / 0", object.OverflowError, "division by zero"},
+		{"let x = 5; x % 0", object.OverflowPromote, "division by zero"},
+		{"9223372036854775807 + 1", object.OverflowPromote, "9223372036854775808"},
+		{"let f = fn(x) { x * x }; f(4294967296)", object.OverflowPromote, "18446744073709551616"},
+		{"-(-9223372036854775807 - 1)", object.OverflowPromote, "9223372036854775808"},
+		{"(9223372036854775807 + 1) - 1", object.OverflowPromote, "9223372036854775807"},
+		{"9223372036854775807 + 1 > 9223372036854775807", object.OverflowPromote, "true"},
+		{"(9223372036854775807 + 1) * 0.5", object.OverflowPromote, "4.611686018427388e+18"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		vm.SetIntegerOverflow(tt.overflow)
+
+		var actual string
+		err = vm.Run()
+		if err != nil {
+			actual = err.(*RuntimeError).Message
+		} else {
+			actual = vm.LastPoppedStackElem().Inspect()
+		}
+
+		if actual != tt.expected {
+			t.Errorf("%q: want=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
 func TestBooleanExpressions(t *testing.T) {
 	tests := []vmTestCase{
 		{"true", true},
@@ -51,6 +125,26 @@ func TestBooleanExpressions(t *testing.T) {
 		{"1 != 1", false},
 		{"1 == 2", false},
 		{"1 != 2", true},
+		{"1.5 < 2", true},
+		{"2 > 1.5", true},
+		{"2 == 2.0", true},
+		{"2.5 != 2.5", false},
+		{"2.0 <= 2", true},
+		{"1 <= 2", true},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"3 >= 2", true},
+		{"let a = 1; let f = fn() { a = 5; 3 }; a < f()", true},
+		{"let a = 1; let f = fn() { a = 5; 3 }; a >= f()", false},
+		{`"a" == "a"`, true},
+		{`"a" != "a"`, false},
+		{`"a" == "b"`, false},
+		{`"a" != "b"`, true},
+		{`"mon" + "key" == "monkey"`, true},
+		{`"é" == "é"`, true},
+		{`"1" == 1`, false},
 		{"true == true", true},
 		{"false == false", true},
 		{"true == false", false},
@@ -72,6 +166,51 @@ func TestBooleanExpressions(t *testing.T) {
 	runVmTests(t, tests)
 }
 
+func TestLogicalExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"true && true", true},
+		{"true && false", false},
+		{"false && true", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 < 2 && 2 < 3", true},
+		{"1 > 2 || 2 > 3", false},
+		{"1 && 2", 2},
+		{"0 || 5", 0},
+		{"false || 5", 5},
+		{"false && 5", false},
+		{"if (1 < 2 && (false || 3 > 2)) { 10 } else { 20 }", 10},
+		{"let c = 0; let f = fn() { c += 1; true }; false && f(); true || f(); true && f(); c", 1},
+		{"let f = fn(a, b) { a || b }; [f(false, 2), f(3, 4)]", []int{2, 3}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestNullExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"null", Null},
+		{"null == null", true},
+		{"1 != null", true},
+		{"if (null) { 1 } else { 2 }", 2},
+		{"null ?? 5", 5},
+		{"0 ?? 5", 0},
+		{"false ?? 5", false},
+		{"null ?? null ?? 3", 3},
+		{`{"a": 1}["b"] ?? 2`, 2},
+		{"let c = 0; let f = fn() { c += 1; 1 }; 1 ?? f(); null ?? f(); c", 1},
+		{`let h = {"a": {"b": 1}}; h?["a"]?["b"]`, 1},
+		{`let h = null; h?["a"]`, Null},
+		{`let h = {"a": null}; h["a"]?.["b"] ?? 7`, 7},
+		{"let f = null; f?.(1)", Null},
+		{"let f = fn(x) { x * 2 }; f?.(21)", 42},
+		{"let c = 0; let f = null; f?.(c += 1); null?[c += 1]; c", 0},
+		{"let f = fn(h) { h?[0] ?? -1 }; [f([5]), f(null)]", []int{5, -1}},
+	}
+
+	runVmTests(t, tests)
+}
+
 func TestConditionals(t *testing.T) {
 	tests := []vmTestCase{
 		{"if (true) { 10 }", 10},
@@ -89,6 +228,764 @@ func TestConditionals(t *testing.T) {
 	runVmTests(t, tests)
 }
 
+func TestElseIfExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"if (false) { 1 } else if (true) { 2 } else { 3 }", 2},
+		{"if (false) { 1 } else if (false) { 2 } else { 3 }", 3},
+		{"if (false) { 1 } else if (false) { 2 }", Null},
+		{"let f = fn(n) { if (n < 0) { -1 } else if (n == 0) { 0 } else { 1 } }; f(-5) + f(0) * 10 + f(7) * 100", 99},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestMatchExpressions(t *testing.T) {
+	describe := `let describe = fn(v) {
+		match (v) {
+			1 => "one",
+			"a" => "letter a",
+			-2.5 => "negative float",
+			true => "true",
+			null => "null",
+			[] => "empty array",
+			[x, 0] => "pair ending in zero after ${x}",
+			[[a, b], c] => a + b + c,
+			{"name": name, "tags": [first, _]} => "${name} tagged ${first}",
+			{} => "a hash",
+			_ => "something else",
+		}
+	};`
+
+	tests := []vmTestCase{
+		{describe + `describe(1)`, "one"},
+		{describe + `describe("a")`, "letter a"},
+		{describe + `describe(-2.5)`, "negative float"},
+		{describe + `describe(true)`, "true"},
+		{describe + `describe(null)`, "null"},
+		{describe + `describe([])`, "empty array"},
+		{describe + `describe([7, 0])`, "pair ending in zero after 7"},
+		{describe + `describe([[1, 2], 3])`, 6},
+		{describe + `describe({"name": "Ann", "tags": ["x", "y"], "age": 3})`, "Ann tagged x"},
+		{describe + `describe({"name": "Ann", "tags": ["x"]})`, "a hash"},
+		{describe + `describe(1.0)`, "something else"},
+		{describe + `describe("b")`, "something else"},
+		{describe + `describe([1, 2, 3])`, "something else"},
+		{"match (2) { 1 => 10, 2 => 20, 2 => 30 }", 20},
+		{"match (3) { 1 => 10 }", Null},
+		{"match (5) { n => n * 2 }", 10},
+		{"let x = 1; match ([2, 3]) { [x, 4] => x, _ => x }", 1},
+		{"match (1) { 1 => { let y = 4; y * 2 } _ => 0 }", 8},
+		{"let f = fn() { match (1) { 1 => { return 5; } }; 10 }; f()", 5},
+		{"match (match (1) { 1 => [2], _ => [] }) { [n] => n, _ => 0 }", 2},
+		{"let f = fn(p) { match (p) { [a, b] => fn() { a * b } } }; f([6, 7])()", 42},
+		{"let total = 0; for (p in [[1, 2], [3], [4, 5]]) { match (p) { [a, b] => { total += a * b; } } } total", 22},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestDestructuringLetStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"let [a, b] = [1, 2]; a * 10 + b", 12},
+		{"let [a, b] = [1]; b ?? 5", 5},
+		{"let [a, b = 2] = [1]; a + b", 3},
+		{"let [a, b = 2] = [1, null]; b", 2},
+		{"let [a, b = a * 3] = [4]; b", 12},
+		{"let [a, _, c] = [1, 2, 3]; a + c", 4},
+		{"let [first, ...rest] = [1, 2, 3]; first + len(rest) * 10 + rest[1]", 24},
+		{"let [...all] = []; len(all)", 0},
+		{"let [a, ...rest] = [1]; len(rest)", 0},
+		{`let {"x": x, "y": y} = {"x": 3, "y": 4}; x * y`, 12},
+		{`let {name, age = 3} = {"name": 1}; name + age`, 4},
+		{`let {a, ...others} = {"a": 1, "b": 2, "c": 3}; (others["a"] ?? 20) + others["c"]`, 23},
+		{`let {1: one, true: yes} = {1: 5, true: 6}; one + yes`, 11},
+		{`let [[a, b], {"c": [c]}] = [[1, 2], {"c": [3]}]; a + b + c`, 6},
+		{"let a = 1; let b = 2; let [a, b] = [b, a]; a * 10 + b", 21},
+		{"let f = fn(pair) { let [a, b] = pair; a - b }; f([5, 3])", 2},
+		{"let f = fn() { let [a, ...r] = [1, 2]; fn() { a + r[0] } }; f()()", 3},
+		{"let [x] = [7]; match ([x, 8]) { [first, ...rest] => first + rest[0], _ => 0 }", 15},
+		{`match ({"a": 1, "b": 2}) { {"a": a, ...rest} => a + rest["b"], _ => 0 }`, 3},
+		{"match ([1]) { [a, b, ...rest] => 1, _ => 2 }", 2},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestConstStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"const x = 5; x", 5},
+		{`const [a, {b}] = [1, {"b": 2}]; a + b`, 3},
+		{"const x = 1; let f = fn() { let x = 2; x = 3; x }; f() + x", 4},
+		{"const x = 1; let f = fn(x) { x += 1; x }; f(5)", 6},
+		{"const f = fn(n) { if (n == 0) { 0 } else { n + f(n - 1) } }; f(3)", 6},
+		{"let a = freeze([1, [2]]); a[0] + a[1][0]", 3},
+		{"let a = freeze([1]); let b = push(a, 2); b[0] = 5; b[0] + a[0]", 6},
+		{"let [first, ...rest] = freeze([1, 2]); rest[0] = 7; rest[0]", 7},
+		{"const a = [1]; a[0] = 4; a[0]", 4},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestTopLevelReturn(t *testing.T) {
+	tests := []vmTestCase{
+		{"return 5;", 5},
+		{"return 10; 9", 10},
+		{"puts(1); return 2 * 5; 9;", 10},
+		{"let x = 3; if (x > 2) { return x; } 0", 3},
+		{"for (x in [1, 2, 3]) { if (x == 2) { return x * 10; } } 0", 20},
+		{"let f = fn() { return 1; }; return f() + 1;", 2},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestWhileStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"while (false) { 10 }; 1", 1},
+		{"let f = fn() { while (true) { break; } 5 }; f()", 5},
+		{"let f = fn(n) { while (n > 0) { return n; } 0 }; f(3)", 3},
+		{"let f = fn(n) { while (n > 0) { return n; } 0 }; f(0)", 0},
+		{"let f = fn() { while (true) { if (true) { break; } 99 } 7 }; f()", 7},
+		{"let f = fn(n) { while (n > 0) { if (n > 1) { continue; } return 1; } 2 }; f(0)", 2},
+		{`
+		let sum = fn(arr) {
+			let go = fn(i, acc) {
+				while (i < len(arr)) {
+					return go(i + 1, acc + arr[i]);
+				}
+				acc
+			};
+			go(0, 0)
+		};
+		sum([1, 2, 3, 4])`, 10},
+		{"if (true) { let a = 1; }", Null},
+		{"if (true) { while (false) {} }", Null},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBreakAndContinueInExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let i = 0; let seen = []; while (i < 3) { i += 1; seen = push(seen, if (i == 2) { break; } else { i }); }; seen", []int{1}},
+		{"let i = 0; let seen = []; while (i < 3) { i += 1; seen = push(seen, if (i == 2) { continue; } else { i }); }; seen", []int{1, 3}},
+		{"let i = 0; while (true) { i += 1; let r = if (i == 3) { break; }; }; i", 3},
+		{"let n = 0; for (x in [1, 2, 3]) { n += if (x == 2) { continue; } else { x }; }; n", 4},
+		{"let n = 0; for (x in [1, 2, 3]) { n = n + -(if (x == 3) { break; } else { x }); }; n", -3},
+		{"let f = fn() { [1, if (true) { return 2; }, 3] }; f()", 2},
+		{"let f = fn() { while (false) {} }; f()", Null},
+		{"let f = fn() { for (x in []) {} }; f()", Null},
+		{"let f = fn() { let x = 1; }; f()", Null},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestForStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"for (x in []) { 1 }; 7", 7},
+		{"for (x in [1, 2, 3]) { }; x", 3},
+		{"for (i, x in [4, 5, 6]) { }; i", 2},
+		{"let f = fn(arr) { for (x in arr) { if (x > 2) { return x; } } 0 }; f([1, 2, 3, 4])", 3},
+		{"let f = fn() { for (x in [1, 2, 3]) { if (x < 3) { continue; } return x * 10; } }; f()", 30},
+		{"for (x in [1, 2]) { for (y in [10, 20]) { break; } }; x + y", 12},
+		{"let f = fn() { for (x in [1, 2, 3]) { for (y in [1]) { break; } } 5 }; [f(), 6]", []int{5, 6}},
+		{`for (k in {"b": 2, "a": 1}) { break; }; k`, "a"},
+		{`for (k, v in {"b": 2, "a": 1, "c": 3}) { if (v == 2) { break; } }; k`, "b"},
+		{`for (k, v in {3: 1, 10: 2, -1: 3}) { break; }; k`, -1},
+		{`for (i, c in "héllo") { }; c`, "o"},
+		{`for (i, c in "héllo") { }; i`, 4},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let a = 5; a = 10; a;", 10},
+		{"let a = 5; a = a + 1;", 6},
+		{"let a = 5; a += 2; a -= 10; a", -3},
+		{"let a = 1; let b = 2; a = b = 7; a + b", 14},
+		{"let a = 1; let f = fn() { a = 5; }; f(); a", 5},
+		{"let f = fn(x) { x += 1; x }; f(1)", 2},
+		{"let a = 1; let f = fn() { let a = 2; a = 3; }; f(); a", 1},
+		{"let i = 0; let sum = 0; while (i < 5) { i += 1; sum += i; } sum", 15},
+		{"let f = fn() { let i = 0; while (true) { i += 1; if (i > 3) { break; } } i }; f()", 4},
+		{"let sum = 0; for (x in [1, 2, 3]) { if (x == 2) { continue; } sum += x; } sum", 4},
+		{"let counter = fn() { let c = 0; fn() { c += 1 } }(); counter(); counter(); counter()", 3},
+		{"let make = fn() { let c = 0; let inc = fn() { c += 1 }; inc(); inc(); c }; make()", 2},
+		{"let pair = fn() { let c = 0; [fn() { c += 1 }, fn() { c }] }(); pair[0](); pair[0](); pair[1]()", 2},
+		{"let f = fn() { let c = 0; let g = fn() { let h = fn() { c += 5 }; h() }; g(); c }; f()", 5},
+		{"let f = fn() { let c = 1; let g = fn() { c }; c = 7; g() }; f()", 7},
+		{"let f = fn(x) { let g = fn() { x = x * 2 }; g(); x }; f(4)", 8},
+		{"let make = fn() { let c = 0; fn() { c += 1 } }; let a = make(); let b = make(); a(); a(); b()", 1},
+		{"let a = [1, 2, 3]; a[1] = 5; a", []int{1, 5, 3}},
+		{"let a = [1, 2, 3]; a[2] += 10", 13},
+		{`let h = {"a": 1}; h["b"] = 2; h["a"] -= 5; h["a"] + h["b"]`, -2},
+		{"let a = [[1]]; a[0][0] = 4; a[0][0]", 4},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestGlobalLetStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"let one = 1; one", 1},
+		{"let one = 1; let two = 2; one + two", 3},
+		{"let one = 1; let two = one + one; one + two", 3},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestStringExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{`"monkey"`, "monkey"},
+		{`"mon" + "key"`, "monkey"},
+		{`"mon" + "key" + "banana"`, "monkeybanana"},
+		{`let name = "monkey"; "hello ${name}!"`, "hello monkey!"},
+		{`"${1} + ${2.5} = ${1 + 2.5}"`, "1 + 2.5 = 3.5"},
+		{`"${[1, true]} ${{"a": 1}} ${if (false) { 1 }}"`, "[1, true] {a: 1} null"},
+		{`"${"outer ${"inner"}"}"`, "outer inner"},
+		{`let f = fn(x) { "<${x}>" }; f(f(1))`, "<<1>>"},
+		{`"\${not} $interpolated"`, "${not} $interpolated"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestArrayLiterals(t *testing.T) {
+	tests := []vmTestCase{
+		{"[]", []int{}},
+		{"[1, 2, 3]", []int{1, 2, 3}},
+		{"[1 + 2, 3 * 4, 5 + 6]", []int{3, 12, 11}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestHashLiterals(t *testing.T) {
+	tests := []vmTestCase{
+		{"{}", map[object.HashKey]int64{}},
+		{
+			"{1: 2, 2: 3}",
+			map[object.HashKey]int64{
+				(&object.Integer{Value: 1}).HashKey(): 2,
+				(&object.Integer{Value: 2}).HashKey(): 3,
+			},
+		},
+		{
+			"{1 + 1: 2 * 2, 3 + 3: 4 * 4}",
+			map[object.HashKey]int64{
+				(&object.Integer{Value: 2}).HashKey(): 4,
+				(&object.Integer{Value: 6}).HashKey(): 16,
+			},
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestIndexExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"[1, 2, 3][1]", 2},
+		{"[1, 2, 3][0 + 2]", 3},
+		{"[[1, 1, 1]][0][0]", 1},
+		{"[][0]", Null},
+		{"[1, 2, 3][99]", Null},
+		{"[1][-1]", Null},
+		{"{1: 1, 2: 2}[1]", 1},
+		{"{1: 1, 2: 2}[2]", 2},
+		{"{1: 1}[0]", Null},
+		{"{}[0]", Null},
+		{`{"one": 1}["o" + "ne"]`, 1},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestCallingFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let fivePlusTen = fn() { 5 + 10; }; fivePlusTen();", 15},
+		{"let one = fn() { 1; }; let two = fn() { 2; }; one() + two()", 3},
+		{"let a = fn() { 1 }; let b = fn() { a() + 1 }; let c = fn() { b() + 1 }; c();", 3},
+		{"let earlyExit = fn() { return 99; 100; }; earlyExit();", 99},
+		{"let earlyExit = fn() { return 99; return 100; }; earlyExit();", 99},
+		{"let noReturn = fn() { }; noReturn();", Null},
+		{"let noReturn = fn() { }; let noReturnTwo = fn() { noReturn(); }; noReturn(); noReturnTwo();", Null},
+		{"let returnsOne = fn() { 1; }; let returnsOneReturner = fn() { returnsOne; }; returnsOneReturner()();", 1},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestCallingFunctionsWithBindings(t *testing.T) {
+	tests := []vmTestCase{
+		{"let one = fn() { let one = 1; one }; one();", 1},
+		{"let oneAndTwo = fn() { let one = 1; let two = 2; one + two; }; oneAndTwo();", 3},
+		{`
+		let oneAndTwo = fn() { let one = 1; let two = 2; one + two; };
+		let threeAndFour = fn() { let three = 3; let four = 4; three + four; };
+		oneAndTwo() + threeAndFour();
+		`, 10},
+		{`
+		let globalSeed = 50;
+		let minusOne = fn() { let num = 1; globalSeed - num; }
+		let minusTwo = fn() { let num = 2; globalSeed - num; }
+		minusOne() + minusTwo();
+		`, 97},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestCallingFunctionsWithArgumentsAndBindings(t *testing.T) {
+	tests := []vmTestCase{
+		{"let identity = fn(a) { a; }; identity(4);", 4},
+		{"let sum = fn(a, b) { a + b; }; sum(1, 2);", 3},
+		{"let sum = fn(a, b) { let c = a + b; c; }; sum(1, 2) + sum(3, 4);", 10},
+		{`
+		let globalNum = 10;
+		let sum = fn(a, b) { let c = a + b; c + globalNum; };
+		let outer = fn() { sum(1, 2) + sum(3, 4) + globalNum; };
+		outer() + globalNum;
+		`, 50},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestFunctionDefaultsRestAndSpread(t *testing.T) {
+	tests := []vmTestCase{
+		{"let add = fn(x, y = 2) { x + y }; add(1)", 3},
+		{"let add = fn(x, y = 2) { x + y }; add(1, 5)", 6},
+		{"let add = fn(x, y = 2) { x + y }; add(1, null)", 3},
+		{"let f = fn(x, y = x * 10) { y }; f(3)", 30},
+		{"let f = fn(a = 1, b = a + 1) { a + b }; f()", 3},
+		{"let b = 1; let f = fn(a, b = 2) { a + b }; f(5)", 7},
+		{"let base = 7; let f = fn(x = base) { x }; f()", 7},
+		{"let f = fn() { let base = 8; fn(x = base) { x } }; f()()", 8},
+		{"let f = fn(a = 1, b = 2, c = 3) { a * 100 + b * 10 + c }; f(4, null, 6)", 426},
+		{"let f = fn(n, acc = 0) { if (n == 0) { acc } else { f(n - 1, acc + n) } }; f(4)", 10},
+		{"let f = fn(...rest) { len(rest) }; f()", 0},
+		{"let f = fn(...rest) { len(rest) }; f(1, 2, 3)", 3},
+		{"let f = fn(x, ...rest) { x + rest[1] }; f(1, 2, 3)", 4},
+		{"let f = fn(x, y = 5, ...rest) { x + y + len(rest) }; f(1)", 6},
+		{"let f = fn(x, y = 5, ...rest) { x + y + len(rest) }; f(1, 2, 3, 4)", 5},
+		{"let f = fn(x, ...rest) { fn() { x + len(rest) } }; f(1, 2)()", 2},
+		{"let add = fn(x, y) { x + y }; add(...[1, 2])", 3},
+		{"let add = fn(x, y) { x + y }; add(1, ...[2])", 3},
+		{"let f = fn(a, b, c, d) { a * 1000 + b * 100 + c * 10 + d }; f(...[1], 2, ...[], ...[3, 4])", 1234},
+		{"let sum = fn(...xs) { let t = 0; for (x in xs) { t += x } t }; sum(...[1, 2], 3, ...[4])", 10},
+		{"let xs = [1]; let f = fn(...a) { len(a) }; f(...xs, ...xs); len(xs)", 1},
+		{`len(...["four"])`, 4},
+		{"let f = fn(x) { x }; f?.(...[5])", 5},
+		{"let f = fn(x, y = 2, z = 3) { x * 100 + y * 10 + z }; f(1, z: 5)", 125},
+		{"let f = fn(x, y = 2, z = 3) { x * 100 + y * 10 + z }; f(z: 5, y: 4, x: 1)", 145},
+		{"let f = fn(x, y = 2, z = 3) { x * 100 + y * 10 + z }; f(y: null, x: 1)", 123},
+		{"let f = fn(a, b = a * 2) { b }; f(a: 4)", 8},
+		{"let f = fn(a, ...rest) { a + len(rest) }; f(a: 4)", 4},
+		{"let f = fn(x) { fn(y = 1, z = 2) { x + y * z } }; f(1)(z: 10)", 11},
+		{"let f = fn(x) { x }; f?.(x: 5)", 5},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestCallingFunctionsWithWrongArguments(t *testing.T) {
+	tests := []vmTestCase{
+		{`fn() { 1; }(1);`, `wrong number of arguments: want=0, got=1`},
+		{`fn(a) { a; }();`, `wrong number of arguments: want=1, got=0`},
+		{`fn(a, b) { a + b; }(1);`, `wrong number of arguments: want=2, got=1`},
+		{`fn(a, b = 1) { a + b; }();`, `wrong number of arguments: want=1 to 2, got=0`},
+		{`fn(a, b = 1) { a + b; }(1, 2, 3);`, `wrong number of arguments: want=1 to 2, got=3`},
+		{`fn(a, ...b) { a; }();`, `wrong number of arguments: want=at least 1, got=0`},
+		{`fn(a) { a; }(...[1, 2]);`, `wrong number of arguments: want=1, got=2`},
+		{`fn(a, b = 1) { a + b; }(b: 2);`, `missing argument for parameter a`},
+		{`fn(a, b) { a + b; }(a: 2);`, `missing argument for parameter b`},
+		{`fn(a) { a; }(1, a: 2);`, `parameter a got more than one argument`},
+		{`fn(a, ...b) { a; }(b: 2);`, `unknown parameter b`},
+		{`len(x: "one");`, `builtin functions don't take named arguments`},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err == nil {
+			t.Fatalf("expected VM error but resulted in none.")
+		}
+
+		runtimeError, ok := err.(*RuntimeError)
+		if !ok {
+			t.Fatalf("error is not RuntimeError. got=%T (%+v)", err, err)
+		}
+
+		if runtimeError.Message != tt.expected {
+			t.Errorf("wrong VM error: expected=%q, actual=%q", tt.expected, runtimeError.Message)
+		}
+	}
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("hello world")`, 11},
+		{`len("ñ")`, 1},
+		{`len("héllo")`, 5},
+		{`len([1, 2, 3])`, 3},
+		{`len([])`, 0},
+		{`puts("hello", "world!")`, Null},
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, Null},
+		{`last([1, 2, 3])`, 3},
+		{`last([])`, Null},
+		{`rest([1, 2, 3])`, []int{2, 3}},
+		{`rest([])`, Null},
+		{`push([], 1)`, []int{1}},
+		{`let wrapper = fn(x) { len(x) }; wrapper([1, 2])`, 2},
+		{`int(2.9)`, 2},
+		{`int("42")`, 42},
+		{`floor(-2.5)`, -3},
+		{`round(2.5)`, 3},
+		{`float(1)`, 1.0},
+		{`float("0.25")`, 0.25},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBuiltinFunctionErrors(t *testing.T) {
+	tests := []vmTestCase{
+		{`len(1)`, "Invalid argument passed to `len()`. Got=INTEGER"},
+		{`len("one", "two")`, "Invalid amount of arguments. Expected=1, got=2"},
+		{`first(1)`, "Invalid argument passed to `first()`. Expected=ARRAY, got=INTEGER"},
+		{`push(1, 1)`, "Invalid argument passed to `push()`. Expected=ARRAY, got=INTEGER"},
+		{`for (x in 1) { }`, "cannot iterate over INTEGER"},
+		{"let a = [1]; a[1] = 2", "index out of range: 1"},
+		{"+true", "unsupported type for unary plus: BOOLEAN"},
+		{`int(1e19)`, "1e+19 is out of the integer range"},
+		{`round("1")`, "Invalid argument passed to `round()`. Got=STRING"},
+		{"let a = 1; a += true", "unsupported types of binary operation: INTEGER BOOLEAN"},
+		{"let a = 1; a[0] = 1", "Index assignment is not defined on type: INTEGER"},
+		{"let h = {}; h[fn() {}] = 1", "Unusable as hash key: CLOSURE"},
+		{"let [a] = 1", "cannot destructure INTEGER as an array"},
+		{`let {a} = [1]`, "cannot destructure ARRAY as a hash"},
+		{"let f = fn(x) { x }; f(...1)", "cannot spread INTEGER"},
+		{"let a = freeze([1]); a[0] = 2", "cannot modify frozen ARRAY"},
+		{"let a = freeze([[1]]); a[0][0] += 2", "cannot modify frozen ARRAY"},
+		{`let h = freeze({"k": {"j": 1}}); h["k"]["j"] = 2`, "cannot modify frozen HASH"},
+		{`let h = freeze({}); h["k"] = 1`, "cannot modify frozen HASH"},
+		{"freeze(1)", "Invalid argument passed to `freeze()`. Expected=ARRAY or HASH, got=INTEGER"},
+		{"let f = fn(x = 1 + true) { x }; f()", "unsupported types of binary operation: INTEGER BOOLEAN"},
+		{`let [{a}] = ["a"]`, "cannot destructure STRING as a hash"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err == nil {
+			t.Fatalf("expected VM error but resulted in none.")
+		}
+
+		runtimeError, ok := err.(*RuntimeError)
+		if !ok {
+			t.Fatalf("error is not RuntimeError. got=%T (%+v)", err, err)
+		}
+
+		if runtimeError.Message != tt.expected {
+			t.Errorf("wrong VM error: expected=%q, actual=%q", tt.expected, runtimeError.Message)
+		}
+	}
+}
+
+func TestUnsetBindings(t *testing.T) {
+	tests := []vmTestCase{
+		{"if (false) { let y = 1 }; puts(y + 1)", "identifier not found: y"},
+		{"fn() { if (false) { let y = 1 }; y + 1 }()", "identifier not found: y"},
+		{"fn() { if (false) { let y = 1 }; fn() { y }() }()", "identifier not found: y"},
+		{"let f = fn() { if (false) { let y = 1 }; fn() { y } }; f()()", "identifier not found: y"},
+		{"for (x in []) {}; puts(x)", "identifier not found: x"},
+		{"fn() { for (x in []) {}; x }()", "identifier not found: x"},
+		{"fn() { for (k, v in {}) {}; fn() { k }() }()", "identifier not found: k"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err == nil {
+			t.Fatalf("%q: expected VM error but resulted in none.", tt.input)
+		}
+
+		runtimeError, ok := err.(*RuntimeError)
+		if !ok {
+			t.Fatalf("error is not RuntimeError. got=%T (%+v)", err, err)
+		}
+
+		if runtimeError.Message != tt.expected {
+			t.Errorf("%q: wrong VM error: expected=%q, actual=%q", tt.input, tt.expected, runtimeError.Message)
+		}
+	}
+}
+
+func TestOperandLimits(t *testing.T) {
+	arguments := make([]string, 255)
+	for i := range arguments {
+		arguments[i] = "1"
+	}
+
+	// Identifiers can't contain digits, so the locals are named va, vb, ...
+	var locals, sum strings.Builder
+	sum.WriteString("0")
+	for i := 0; i < 256; i++ {
+		name := "v" + string(rune('a'+i/26)) + string(rune('a'+i%26))
+		fmt.Fprintf(&locals, "let %s = %d; ", name, i)
+		if i < 255 {
+			fmt.Fprintf(&sum, " + %s", name)
+		}
+	}
+
+	tests := []vmTestCase{
+		{fmt.Sprintf("fn(...xs) { len(xs) }(%s)", strings.Join(arguments, ", ")), 255},
+		{fmt.Sprintf("fn() { %s %s }()", locals.String(), sum.String()), 32385},
+		{fmt.Sprintf("fn() { %s vjv }()", locals.String()), 255},
+		{fmt.Sprintf("fn() { %s fn() { %s }() }()", locals.String(), sum.String()), 32385},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestOutputIsPerMachine(t *testing.T) {
+	var first, second bytes.Buffer
+
+	machines := []*VM{}
+	for _, input := range []string{`let f = fn() { puts("one") }; f()`, `puts("two", 2)`} {
+		comp := compiler.New()
+		err := comp.Compile(parse(input))
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		machines = append(machines, New(comp.Bytecode()))
+	}
+	machines[0].SetOutput(&first)
+	machines[1].SetOutput(&second)
+
+	for _, machine := range machines {
+		err := machine.Run()
+		if err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+	}
+
+	if first.String() != "one\n" {
+		t.Errorf("wrong output of the first machine. expected=%q, got=%q", "one\n", first.String())
+	}
+	if second.String() != "two\n2\n" {
+		t.Errorf("wrong output of the second machine. expected=%q, got=%q", "two\n2\n", second.String())
+	}
+}
+
+func TestClosures(t *testing.T) {
+	tests := []vmTestCase{
+		{`
+		let newClosure = fn(a) { fn() { a; }; };
+		let closure = newClosure(99);
+		closure();
+		`, 99},
+		{`
+		let newAdder = fn(a, b) { fn(c) { a + b + c }; };
+		let adder = newAdder(1, 2);
+		adder(8);
+		`, 11},
+		{`
+		let add = fn(a) { fn(b) { fn(c) { a + b + c } } };
+		add(1)(2)(3);
+		`, 6},
+		{`
+		let newClosure = fn(a, b) {
+			let one = fn() { a; };
+			let two = fn() { b; };
+			fn() { one() + two(); };
+		};
+		let closure = newClosure(9, 90);
+		closure();
+		`, 99},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestRecursiveFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{`
+		let countDown = fn(x) {
+			if (x == 0) {
+				return 0;
+			} else {
+				countDown(x - 1);
+			}
+		};
+		countDown(1);
+		`, 0},
+		{`
+		let wrapper = fn() {
+			let countDown = fn(x) {
+				if (x == 0) {
+					return 0;
+				} else {
+					countDown(x - 1);
+				}
+			};
+			countDown(1);
+		};
+		wrapper();
+		`, 0},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestRecursiveFibonacci(t *testing.T) {
+	tests := []vmTestCase{
+		{`
+		let fibonacci = fn(x) {
+			if (x == 0) {
+				return 0;
+			} else {
+				if (x == 1) {
+					return 1;
+				} else {
+					fibonacci(x - 1) + fibonacci(x - 2);
+				}
+			}
+		};
+		fibonacci(15);
+		`, 610},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestRuntimeErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + true", "1:3: unsupported types of binary operation: INTEGER BOOLEAN"},
+		{"let a = 1;\nlet b = -true;", "2:9: unsupported type for negation: BOOLEAN"},
+		{"let f = fn(x) {\n  len(x)\n};\nf(1)", "2:6: Invalid argument passed to `len()`. Got=INTEGER"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err == nil {
+			t.Fatalf("expected VM error but resulted in none.")
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong VM error: expected=%q, actual=%q", tt.expected, err.Error())
+		}
+	}
+}
+
+func TestRuntimeErrorStackTraces(t *testing.T) {
+	input := `let inner = fn(x) {
+  x + true
+};
+let outer = fn(y) { inner(y) };
+fn() { outer(1) }();`
+
+	comp := compiler.New()
+	err := comp.Compile(parse(input))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	err = vm.Run()
+
+	runtimeError, ok := err.(*RuntimeError)
+	if !ok {
+		t.Fatalf("error is not RuntimeError. got=%T (%+v)", err, err)
+	}
+
+	expected := []string{
+		"in inner, called at 4:26",
+		"in outer, called at 5:13",
+		"in <anonymous>, called at 5:18",
+	}
+
+	if len(runtimeError.StackTrace) != len(expected) {
+		t.Fatalf("wrong stack trace length. expected=%d, actual=%d (%v)", len(expected), len(runtimeError.StackTrace), runtimeError.StackTrace)
+	}
+
+	for i, frame := range expected {
+		if runtimeError.StackTrace[i].String() != frame {
+			t.Errorf("wrong stack frame %d. expected=%q, actual=%q", i, frame, runtimeError.StackTrace[i].String())
+		}
+	}
+}
+
+func TestGlobalsStoreAcrossPrograms(t *testing.T) {
+	constants := []object.Object{}
+	globals := make([]object.Object, GlobalsSize)
+	symbolTable := compiler.NewSymbolTable()
+
+	inputs := []string{"let one = 1;", "let two = one + 1;", "one + two"}
+
+	var vm *VM
+	for _, input := range inputs {
+		comp := compiler.NewWithState(symbolTable, constants)
+		err := comp.Compile(parse(input))
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		bytecode := comp.Bytecode()
+		constants = bytecode.Constants
+
+		vm = NewWithGlobalsStore(bytecode, globals)
+		err = vm.Run()
+		if err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+	}
+
+	testExpectedObject(t, 3, vm.LastPoppedStackElem())
+}
+
 func runVmTests(t *testing.T, tests []vmTestCase) {
 	t.Helper()
 
@@ -126,11 +1023,62 @@ func testExpectedObject(
 		if err != nil {
 			t.Errorf("testIntegerObject failed: %s", err)
 		}
+	case float64:
+		err := testFloatObject(expected, actual)
+		if err != nil {
+			t.Errorf("testFloatObject failed: %s", err)
+		}
 	case bool:
 		err := testBooleanObject(bool(expected), actual)
 		if err != nil {
 			t.Errorf("testBooleanObject failed: %s", err)
 		}
+	case string:
+		err := testStringObject(expected, actual)
+		if err != nil {
+			t.Errorf("testStringObject failed: %s", err)
+		}
+	case []int:
+		array, ok := actual.(*object.Array)
+		if !ok {
+			t.Errorf("object is not Array: %T (%+v)", actual, actual)
+			return
+		}
+
+		if len(array.Elements) != len(expected) {
+			t.Errorf("wrong number of elements. expected=%d, actual=%d", len(expected), len(array.Elements))
+			return
+		}
+
+		for i, expectedElement := range expected {
+			err := testIntegerObject(int64(expectedElement), array.Elements[i])
+			if err != nil {
+				t.Errorf("testIntegerObject failed: %s", err)
+			}
+		}
+	case map[object.HashKey]int64:
+		hash, ok := actual.(*object.Hash)
+		if !ok {
+			t.Errorf("object is not Hash. got=%T (%+v)", actual, actual)
+			return
+		}
+
+		if len(hash.Pairs) != len(expected) {
+			t.Errorf("hash has wrong number of Pairs. expected=%d, actual=%d", len(expected), len(hash.Pairs))
+			return
+		}
+
+		for expectedKey, expectedValue := range expected {
+			pair, ok := hash.Pairs[expectedKey]
+			if !ok {
+				t.Errorf("no pair for given key in Pairs")
+			}
+
+			err := testIntegerObject(expectedValue, pair.Value)
+			if err != nil {
+				t.Errorf("testIntegerObject failed: %s", err)
+			}
+		}
 	case *object.Null:
 		if actual != Null {
 			t.Errorf("object is not Null: %T (%+v)", actual, actual)
@@ -157,6 +1105,32 @@ func testIntegerObject(expected int64, actual object.Object) error {
 	return nil
 }
 
+func testFloatObject(expected float64, actual object.Object) error {
+	result, ok := actual.(*object.Float)
+	if !ok {
+		return fmt.Errorf("object is not Float. got=%T (%+v)", actual, actual)
+	}
+
+	if result.Value != expected {
+		return fmt.Errorf("object has wrong value. expected=%g, actual=%g", expected, result.Value)
+	}
+
+	return nil
+}
+
+func testStringObject(expected string, actual object.Object) error {
+	result, ok := actual.(*object.String)
+	if !ok {
+		return fmt.Errorf("object is not String. got=%T (%+v)", actual, actual)
+	}
+
+	if result.Value != expected {
+		return fmt.Errorf("object has wrong value. expected=%q, actual=%q", expected, result.Value)
+	}
+
+	return nil
+}
+
 func testBooleanObject(expected bool, actual object.Object) error {
 	result, ok := actual.(*object.Boolean)
 	if !ok {